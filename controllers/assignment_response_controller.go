@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DeclineTaskAssignmentInput struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// respondToAssignment records the answer of the authenticated user to their assignment
+// and notifies the task creator
+func respondToAssignment(c *gin.Context, status string, reason string) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var assignment models.AssignTaskToUser
+	if err := database.DB.Where("task_id = ? AND user_id = ?", task.ID, authUserID).First(&assignment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"You are not assigned to this task"}})
+		return
+	}
+	if assignment.Status != models.AssignmentPending {
+		c.JSON(http.StatusConflict, gin.H{"errors": []string{fmt.Sprintf("You have already %s this assignment", assignment.Status)}})
+		return
+	}
+
+	var user models.User
+	database.DB.First(&user, authUserID)
+
+	now := time.Now()
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&assignment).Updates(map[string]interface{}{
+			"status":         status,
+			"decline_reason": reason,
+			"responded_at":   now,
+		}).Error; err != nil {
+			return err
+		}
+
+		changes := newTaskChangeLog(task.ID, authUserID)
+		changes.add(fmt.Sprintf("assignment:%d", authUserID), models.AssignmentPending, status)
+		if err := changes.save(tx); err != nil {
+			return err
+		}
+		if err := incrementTaskVersion(tx, task.ID); err != nil {
+			return err
+		}
+
+		if task.CreatedBy == authUserID {
+			return nil
+		}
+		message := fmt.Sprintf("%s accepted task '%s'", user.Username, task.NotificationLabel())
+		if status == models.AssignmentDeclined {
+			message = fmt.Sprintf("%s declined task '%s': %s", user.Username, task.NotificationLabel(), reason)
+		}
+		return tx.Create(&models.Notification{
+			UserID:  task.CreatedBy,
+			TaskID:  &task.ID,
+			Type:    "assignment_" + status,
+			Message: message,
+		}).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to respond to assignment"})
+		return
+	}
+
+	assignment.Status = status
+	assignment.DeclineReason = reason
+	assignment.RespondedAt = &now
+
+	c.JSON(http.StatusOK, gin.H{"data": assignment})
+}
+
+// AcceptTaskAssignment lets an assignee acknowledge a task assigned to them
+func AcceptTaskAssignment(c *gin.Context) {
+	respondToAssignment(c, models.AssignmentAccepted, "")
+}
+
+// DeclineTaskAssignment lets an assignee decline a task assigned to them, with a reason
+func DeclineTaskAssignment(c *gin.Context) {
+	var input DeclineTaskAssignmentInput
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"A reason of at most 1000 characters is required to decline a task"}})
+		return
+	}
+	respondToAssignment(c, models.AssignmentDeclined, strings.TrimSpace(input.Reason))
+}
+
+// GetUnacknowledgedAssignments lists the assignments on tasks created by the authenticated
+// user that are still pending, or with ?status=declined the declined ones
+func GetUnacknowledgedAssignments(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	status := c.DefaultQuery("status", models.AssignmentPending)
+	if status != models.AssignmentPending && status != models.AssignmentDeclined {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid status value. Must be pending or declined"}})
+		return
+	}
+
+	var assignments []models.AssignTaskToUser
+	if err := database.DB.
+		Preload("User").
+		Preload("Task").
+		Joins("JOIN tasks ON tasks.id = assign_task_to_users.task_id AND tasks.deleted_at IS NULL").
+		Where("tasks.created_by = ? AND assign_task_to_users.status = ?", authUserID, status).
+		Order("assign_task_to_users.created_at").
+		Find(&assignments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve assignments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": assignments})
+}
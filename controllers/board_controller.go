@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"taskmanager/database"
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// maxBoardRankLength is the rank length above which a column is rebalanced
+const maxBoardRankLength = 32
+
+// boardColumns are the statuses shown on the board, in column order
+var boardColumns = []string{"Pending", "In Progress", "In Review", "Completed"}
+
+type MoveTaskInput struct {
+	Status   string `json:"status" binding:"required,oneof=Pending 'In Progress' 'In Review' Completed"`
+	BeforeID *uint  `json:"before_id" binding:"omitempty,gt=0"` // task shown directly above the new position
+	AfterID  *uint  `json:"after_id" binding:"omitempty,gt=0"`  // task shown directly below the new position
+	Version  *uint  `json:"version"`                            // alternative to the If-Match header
+}
+
+// BoardColumn is one status column of the board
+type BoardColumn struct {
+	Status string        `json:"status"`
+	Tasks  []models.Task `json:"tasks"`
+}
+
+// orderByBoardRank sorts tasks the way the board shows them: tasks that were never moved
+// first (newest on top, as before ranks existed), then the ranked tasks in rank order
+func orderByBoardRank(db *gorm.DB) *gorm.DB {
+	return db.Order("board_rank <> ''").Order("board_rank").Order("created_at DESC").Order("id DESC")
+}
+
+// rankUnrankedTasks gives ranks to the unranked tasks of a column from the given one down,
+// so that it can serve as a neighbour. The unranked tasks are shown above the ranked ones,
+// so ranking the bottom of them, just above the first ranked task, keeps the column order
+// without touching the rest of it.
+func rankUnrankedTasks(tx *gorm.DB, status string, from models.Task) error {
+	var taskIDs []uint
+	if err := orderByBoardRank(tx.Model(&models.Task{})).
+		Where("status = ? AND board_rank = ''", status).
+		Where("created_at < ? OR (created_at = ? AND id <= ?)", from.CreatedAt, from.CreatedAt, from.ID).
+		Pluck("id", &taskIDs).Error; err != nil {
+		return err
+	}
+
+	var first models.Task
+	if err := orderByBoardRank(tx.Where("status = ? AND board_rank <> ''", status)).Limit(1).Find(&first).Error; err != nil {
+		return err
+	}
+
+	ranks := utils.RanksBetween("", first.BoardRank, len(taskIDs))
+	for i, taskID := range taskIDs {
+		// Ranks are not task data, so they change neither the version nor updated_at
+		if err := tx.Model(&models.Task{}).Where("id = ?", taskID).UpdateColumn("board_rank", ranks[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebalanceBoardColumn gives every task of a status an evenly spaced rank, keeping their order
+func rebalanceBoardColumn(tx *gorm.DB, status string) error {
+	var taskIDs []uint
+	if err := orderByBoardRank(tx.Model(&models.Task{}).Where("status = ?", status)).Pluck("id", &taskIDs).Error; err != nil {
+		return err
+	}
+	ranks := utils.EvenRanks(len(taskIDs))
+	for i, taskID := range taskIDs {
+		// Ranks are not task data, so they change neither the version nor updated_at
+		if err := tx.Model(&models.Task{}).Where("id = ?", taskID).UpdateColumn("board_rank", ranks[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boardNeighbour loads a task the moved task is placed next to
+func boardNeighbour(tx *gorm.DB, userID uint, taskID uint, neighbourID uint, status string) (models.Task, string, error) {
+	var neighbour models.Task
+	if neighbourID == taskID {
+		return neighbour, "A task cannot be placed next to itself", nil
+	}
+	if err := tx.First(&neighbour, neighbourID).Error; err != nil {
+		return neighbour, fmt.Sprintf("Task with ID %d not found", neighbourID), nil
+	}
+	visible, err := canViewTask(tx, userID, neighbour)
+	if err != nil {
+		return neighbour, "", err
+	}
+	if !visible {
+		return neighbour, fmt.Sprintf("Task with ID %d not found", neighbourID), nil
+	}
+	if neighbour.Status != status {
+		return neighbour, fmt.Sprintf("Task with ID %d is not in the %s column", neighbourID, status), nil
+	}
+	return neighbour, "", nil
+}
+
+// boardRankFor computes the rank that puts a task between two neighbours of a column.
+// A missing neighbour is replaced by the closest ranked task of the column, so the task
+// lands exactly next to the given one. The message is set for invalid neighbours.
+func boardRankFor(tx *gorm.DB, userID uint, taskID uint, input MoveTaskInput) (string, string, error) {
+	for attempt := 0; ; attempt++ {
+		var before, after models.Task
+		var message string
+		var err error
+		if input.BeforeID != nil {
+			if before, message, err = boardNeighbour(tx, userID, taskID, *input.BeforeID, input.Status); err != nil || message != "" {
+				return "", message, err
+			}
+		}
+		if input.AfterID != nil {
+			if after, message, err = boardNeighbour(tx, userID, taskID, *input.AfterID, input.Status); err != nil || message != "" {
+				return "", message, err
+			}
+		}
+
+		// Tasks that were never moved have no rank yet. Unranked tasks are shown above the
+		// ranked ones, so ranking from the upper neighbour down covers both.
+		if attempt == 0 && ((input.BeforeID != nil && before.BoardRank == "") || (input.AfterID != nil && after.BoardRank == "")) {
+			from := after
+			if input.BeforeID != nil && before.BoardRank == "" {
+				from = before
+			}
+			if err := rankUnrankedTasks(tx, input.Status, from); err != nil {
+				return "", "", err
+			}
+			continue
+		}
+
+		column := tx.Model(&models.Task{}).Where("status = ? AND id <> ? AND board_rank <> ''", input.Status, taskID)
+		if input.AfterID == nil {
+			// The end of the list, or the task ranked right below before
+			query := column.Session(&gorm.Session{}).Order("board_rank")
+			if input.BeforeID != nil {
+				query = query.Where("board_rank > ?", before.BoardRank)
+			}
+			query.Limit(1).Find(&after)
+		}
+		if input.BeforeID == nil && input.AfterID != nil {
+			column.Session(&gorm.Session{}).Where("board_rank < ?", after.BoardRank).Order("board_rank DESC").Limit(1).Find(&before)
+		}
+
+		if before.BoardRank != "" && after.BoardRank != "" && before.BoardRank >= after.BoardRank {
+			return "", "before_id must be shown above after_id", nil
+		}
+
+		rank := utils.RankBetween(before.BoardRank, after.BoardRank)
+		if len(rank) <= maxBoardRankLength || attempt > 0 {
+			return rank, "", nil
+		}
+		if err := rebalanceBoardColumn(tx, input.Status); err != nil {
+			return "", "", err
+		}
+	}
+}
+
+// GetBoard returns the tasks the caller created, is assigned to or follows, grouped into
+// status columns in their manual order
+func GetBoard(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	query, err := myTasksQuery(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+		return
+	}
+
+	var tasks []models.Task
+	if err := orderByBoardRank(query).
+		Preload("AssignedUsers.User").
+		Preload("AssignedGroups.Group").
+		Preload("Creator").
+		Preload("Tags").
+		Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
+		return
+	}
+
+	annotateReadState(database.DB, authUserID, tasks)
+	renderTasksMarkdown(database.DB, authUserID, tasks)
+
+	columns := make([]BoardColumn, len(boardColumns))
+	index := make(map[string]int)
+	for i, status := range boardColumns {
+		columns[i] = BoardColumn{Status: status, Tasks: []models.Task{}}
+		index[status] = i
+	}
+	for _, task := range tasks {
+		if i, ok := index[task.Status]; ok {
+			columns[i].Tasks = append(columns[i].Tasks, task)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": columns})
+}
+
+// MoveTask moves a task on the board: it changes its status and its position within the
+// column in one step. Status changes follow the rules and logging of UpdateTaskStatus.
+func MoveTask(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	// Authorization check: same as UpdateTaskStatus, only assignees
+	assigned, err := isUserAssigned(database.DB, authUserID, task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user assignment"})
+		return
+	}
+	if !assigned {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to move this task"}})
+		return
+	}
+
+	var input MoveTaskInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			_ = ve.Translate(trans)
+			for _, e := range ve {
+				errors = append(errors, e.Translate(trans))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	// Optimistic concurrency check
+	expectedVersion, ok := expectedTaskVersion(c, task, input.Version)
+	if !ok {
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	// Claim the version so a concurrent update cannot overwrite this one
+	if bumped, err := bumpTaskVersion(tx, task.ID, expectedVersion); err != nil || !bumped {
+		tx.Rollback()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+			return
+		}
+		respondTaskVersionConflict(c, task.ID)
+		return
+	}
+
+	rank, message, err := boardRankFor(tx, authUserID, task.ID, input)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+		return
+	}
+	if message != "" {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{message}})
+		return
+	}
+
+	if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).UpdateColumn("board_rank", rank).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+		return
+	}
+
+	if input.Status != task.Status {
+		if err := recordTaskProgress(tx, task, authUserID, input.Status, nil, ""); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
+			return
+		}
+
+		// Create notification for task creator
+		if task.CreatedBy != authUserID {
+			var user models.User
+			database.DB.First(&user, authUserID)
+			notification := models.Notification{
+				UserID:  task.CreatedBy,
+				TaskID:  &task.ID,
+				Type:    "status_update",
+				Message: fmt.Sprintf("Task '%s' status updated to '%s' by %s", task.NotificationLabel(), input.Status, user.Username),
+			}
+			if err := tx.Create(&notification).Error; err != nil {
+				// Handle error
+			}
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	task.Version = expectedVersion + 1
+	c.Header("ETag", taskETag(task))
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"id": task.ID, "status": input.Status, "board_rank": rank, "version": task.Version}})
+}
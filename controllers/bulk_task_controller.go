@@ -0,0 +1,406 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"taskmanager/database"
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// maxBulkTasks caps how many tasks a single bulk request may touch
+const maxBulkTasks = 500
+
+type BulkTaskOperations struct {
+	Status          *string     `json:"status" binding:"omitempty,oneof=Pending 'In Progress' 'In Review' Completed"`
+	Priority        *string     `json:"priority" binding:"omitempty,oneof=Normal Medium High Escalation"`
+	DueDate         *utils.Date `json:"due_date"`
+	ClearDueDate    bool        `json:"clear_due_date"`
+	AddAssignees    []uint      `json:"add_assignees" binding:"omitempty,dive,gt=0"`
+	RemoveAssignees []uint      `json:"remove_assignees" binding:"omitempty,dive,gt=0"`
+}
+
+type BulkTaskInput struct {
+	TaskIDs    []uint                 `json:"task_ids" binding:"omitempty,max=500,dive,gt=0"`
+	Filter     *GetMyTasksFilterInput `json:"filter"`
+	Mode       string                 `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort"`
+	Operations BulkTaskOperations     `json:"operations" binding:"required"`
+	Versions   map[uint]uint          `json:"versions"` // task ID => version the client based its edit on, required with task_ids
+}
+
+// BulkTaskResult reports the outcome of a bulk operation for a single task
+type BulkTaskResult struct {
+	TaskID  uint   `json:"task_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// CurrentVersion is set on a version conflict so the client can reload the task
+	CurrentVersion *uint `json:"current_version,omitempty"`
+}
+
+// errBulkVersionConflict is returned for a task whose version no longer matches the request
+var errBulkVersionConflict = errors.New("version conflict")
+
+// bulkNotifier collects notifications during a bulk request so each user gets one summary
+type bulkNotifier struct {
+	actor   string
+	pending map[uint][]models.Task
+	types   map[uint]string
+	order   []uint
+}
+
+func newBulkNotifier(actor string) *bulkNotifier {
+	return &bulkNotifier{actor: actor, pending: map[uint][]models.Task{}, types: map[uint]string{}}
+}
+
+func (n *bulkNotifier) add(userID uint, task models.Task, notificationType string) {
+	for _, t := range n.pending[userID] {
+		if t.ID == task.ID {
+			return
+		}
+	}
+	if _, ok := n.pending[userID]; !ok {
+		n.order = append(n.order, userID)
+	}
+	n.pending[userID] = append(n.pending[userID], task)
+	// A new assignment is more important to the recipient than a plain update
+	if n.types[userID] != "new_task" {
+		n.types[userID] = notificationType
+	}
+}
+
+// flush writes one notification per recipient. A summary of several tasks has no task.
+func (n *bulkNotifier) flush(db *gorm.DB) error {
+	var notifications []models.Notification
+	for _, userID := range n.order {
+		tasks := n.pending[userID]
+		if len(tasks) == 1 {
+			notifications = append(notifications, models.Notification{
+				UserID:  userID,
+				TaskID:  &tasks[0].ID,
+				Type:    n.types[userID],
+				Message: fmt.Sprintf("Task '%s' was updated by %s", tasks[0].NotificationLabel(), n.actor),
+			})
+			continue
+		}
+
+		var labels []string
+		for _, t := range tasks {
+			labels = append(labels, t.NotificationLabel())
+		}
+		notifications = append(notifications, models.Notification{
+			UserID:  userID,
+			Type:    "bulk_update",
+			Message: fmt.Sprintf("%d tasks were updated by %s: %s", len(tasks), n.actor, strings.Join(labels, ", ")),
+		})
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+	return db.Create(&notifications).Error
+}
+
+// resolveBulkTaskIDs returns the explicit task IDs or, with a filter, the IDs of the
+// caller's tasks (created, assigned or followed) that match it
+func resolveBulkTaskIDs(db *gorm.DB, userID uint, input BulkTaskInput) ([]uint, error) {
+	if len(input.TaskIDs) > 0 {
+		return input.TaskIDs, nil
+	}
+
+	query, err := myTasksQuery(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var taskIDs []uint
+	err = applyMyTasksFilter(query, *input.Filter).Order("id").Limit(maxBulkTasks+1).Pluck("id", &taskIDs).Error
+	return taskIDs, err
+}
+
+// applyBulkOperations applies the operations to one task, enforcing the same rules as
+// UpdateTask (creator only) and UpdateTaskStatus (assignees, and the creator through UpdateTask)
+func applyBulkOperations(tx *gorm.DB, task models.Task, ops BulkTaskOperations, authUserID uint, notifier *bulkNotifier) error {
+	isCreator := task.CreatedBy == authUserID
+	changesTaskFields := ops.Priority != nil || ops.DueDate != nil || ops.ClearDueDate ||
+		len(ops.AddAssignees) > 0 || len(ops.RemoveAssignees) > 0
+
+	if changesTaskFields && !isCreator {
+		return errors.New("you are not authorized to update this task")
+	}
+
+	if ops.Status != nil && !isCreator {
+		assigned, err := isUserAssigned(tx, authUserID, task.ID)
+		if err != nil {
+			return err
+		}
+		if !assigned {
+			return errors.New("you are not authorized to update the status of this task")
+		}
+	}
+
+	changes := newTaskChangeLog(task.ID, authUserID)
+	updates := map[string]interface{}{}
+	if ops.Priority != nil {
+		updates["priority"] = *ops.Priority
+		changes.add("priority", task.Priority, *ops.Priority)
+	}
+	if ops.ClearDueDate {
+		updates["due_date"] = nil
+		changes.add("due_date", formatDate(task.DueDate), "")
+	} else if ops.DueDate != nil {
+		if ops.DueDate.Time.Before(task.StartDate) {
+			return errors.New("due date must be greater than or equal to start date")
+		}
+		updates["due_date"] = ops.DueDate.Time
+		changes.add("due_date", formatDate(task.DueDate), formatDate(&ops.DueDate.Time))
+	}
+	if ops.Status != nil {
+		updates["status"] = *ops.Status
+		changes.add("status", task.Status, *ops.Status)
+		// Completing a task completes its progress, as in UpdateTaskStatus
+		if *ops.Status == "Completed" && task.Status != "Completed" {
+			updates["progress"] = 100
+			changes.add("progress", formatID(task.Progress), "100")
+		}
+	}
+	if len(ops.AddAssignees) > 0 || len(ops.RemoveAssignees) > 0 {
+		before := taskIDSet(tx, &models.AssignTaskToUser{}, "user_id", task.ID)
+		removed := make(map[uint]bool)
+		for _, id := range ops.RemoveAssignees {
+			removed[id] = true
+		}
+		var after []uint
+		for _, id := range append(before, ops.AddAssignees...) {
+			if !removed[id] {
+				after = append(after, id)
+			}
+		}
+		changes.addIDSet("assigned_users", before, after)
+	}
+	if err := changes.save(tx); err != nil {
+		return err
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&task).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	if ops.Status != nil {
+		statusLog := models.TaskStatusUpdateLog{TaskID: task.ID, UserID: authUserID, Status: *ops.Status}
+		if err := tx.Create(&statusLog).Error; err != nil {
+			return err
+		}
+		if err := touchTaskActivity(tx, task.ID, authUserID); err != nil {
+			return err
+		}
+		if !isCreator {
+			notifier.add(task.CreatedBy, task, "status_update")
+		}
+	}
+
+	if len(ops.RemoveAssignees) > 0 {
+		if err := tx.Unscoped().Where("task_id = ? AND user_id IN ?", task.ID, ops.RemoveAssignees).Delete(&models.AssignTaskToUser{}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, userID := range ops.AddAssignees {
+		var existing int64
+		if err := tx.Model(&models.AssignTaskToUser{}).Where("task_id = ? AND user_id = ?", task.ID, userID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			continue
+		}
+		if err := tx.Create(&models.AssignTaskToUser{TaskID: task.ID, UserID: userID}).Error; err != nil {
+			return err
+		}
+		if userID != authUserID {
+			notifier.add(userID, task, "new_task")
+		}
+	}
+
+	return nil
+}
+
+// BulkUpdateTasks applies one set of operations to many tasks and reports the result per task
+func BulkUpdateTasks(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var input BulkTaskInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			_ = ve.Translate(trans)
+			for _, e := range ve {
+				errors = append(errors, e.Translate(trans))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	if len(input.TaskIDs) == 0 && input.Filter == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Either task_ids or filter is required"}})
+		return
+	}
+	if input.Mode == "" {
+		input.Mode = "all_or_nothing"
+	}
+
+	// Validate AddAssignees
+	for _, userID := range input.Operations.AddAssignees {
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("User with ID %d not found", userID)}})
+			return
+		}
+	}
+
+	taskIDs, err := resolveBulkTaskIDs(database.DB, authUserID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve tasks"})
+		return
+	}
+	if len(taskIDs) > maxBulkTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("A bulk request can update at most %d tasks", maxBulkTasks)}})
+		return
+	}
+
+	var tasks []models.Task
+	if len(taskIDs) > 0 {
+		if err := database.DB.Where("id IN ?", taskIDs).Find(&tasks).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
+			return
+		}
+	}
+	taskMap := make(map[uint]models.Task)
+	for _, task := range tasks {
+		taskMap[task.ID] = task
+	}
+
+	var actor models.User
+	database.DB.First(&actor, authUserID)
+	notifier := newBulkNotifier(actor.Username)
+
+	results := make([]BulkTaskResult, 0, len(taskIDs))
+	failed := 0
+	conflicts := 0
+
+	runOne := func(tx *gorm.DB, taskID uint, n *bulkNotifier) error {
+		task, ok := taskMap[taskID]
+		if !ok {
+			return errors.New("task not found")
+		}
+
+		// Explicitly selected tasks were edited from a copy the client holds, so their version
+		// must match. Tasks selected by a filter are only guarded against concurrent bumps.
+		if expected, ok := input.Versions[taskID]; ok {
+			bumped, err := bumpTaskVersion(tx, taskID, expected)
+			if err != nil {
+				return err
+			}
+			if !bumped {
+				return errBulkVersionConflict
+			}
+		} else if len(input.TaskIDs) > 0 {
+			return errors.New("version is required")
+		} else if err := incrementTaskVersion(tx, taskID); err != nil {
+			return err
+		}
+
+		return applyBulkOperations(tx, task, input.Operations, authUserID, n)
+	}
+
+	newResult := func(taskID uint, err error) BulkTaskResult {
+		result := BulkTaskResult{TaskID: taskID, Success: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+			if errors.Is(err, errBulkVersionConflict) {
+				conflicts++
+				var current models.Task
+				if database.DB.Select("id", "version").First(&current, taskID).Error == nil {
+					result.CurrentVersion = &current.Version
+				}
+			}
+		}
+		return result
+	}
+
+	if input.Mode == "all_or_nothing" {
+		txErr := database.DB.Transaction(func(tx *gorm.DB) error {
+			for _, taskID := range taskIDs {
+				results = append(results, newResult(taskID, runOne(tx, taskID, notifier)))
+			}
+			if failed > 0 {
+				return errors.New("bulk update aborted")
+			}
+			return nil
+		})
+		if txErr != nil {
+			// Nothing was applied, so tasks that passed are reported as not applied too
+			for i := range results {
+				if results[i].Success {
+					results[i].Success = false
+					results[i].Error = "not applied because other tasks failed"
+				}
+			}
+			status := http.StatusConflict
+			if conflicts > 0 {
+				status = http.StatusPreconditionFailed
+			}
+			c.JSON(status, gin.H{"errors": []string{"No tasks were updated"}, "results": results})
+			return
+		}
+	} else {
+		for _, taskID := range taskIDs {
+			// Keep the notifications of failed tasks out of the batch
+			snapshot := newBulkNotifier(actor.Username)
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				return runOne(tx, taskID, snapshot)
+			})
+			result := newResult(taskID, err)
+			if err == nil {
+				for _, userID := range snapshot.order {
+					for _, task := range snapshot.pending[userID] {
+						notifier.add(userID, task, snapshot.types[userID])
+					}
+				}
+			}
+			results = append(results, result)
+		}
+	}
+
+	// The updates are committed, so a failed notification does not fail the request
+	if err := notifier.flush(database.DB); err != nil {
+		log.Printf("bulk update: failed to send notifications: %v", err)
+	}
+
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{"data": gin.H{
+		"mode":      input.Mode,
+		"total":     len(taskIDs),
+		"succeeded": len(taskIDs) - failed,
+		"failed":    failed,
+		"results":   results,
+	}})
+}
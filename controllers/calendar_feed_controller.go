@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// calendarRefreshMinutes is how often calendar clients are asked to poll a feed
+const calendarRefreshMinutes = 15
+
+type CreateCalendarFeedInput struct {
+	Scope      string `json:"scope" binding:"required,oneof=user group task_type"`
+	Kind       string `json:"kind" binding:"omitempty,oneof=todo event"`
+	GroupID    *uint  `json:"group_id" binding:"omitempty,gt=0"`
+	TaskTypeID *uint  `json:"task_type_id" binding:"omitempty,gt=0"`
+}
+
+// CalendarFeedResponse is a feed together with its secret URL
+type CalendarFeedResponse struct {
+	models.CalendarFeed
+	URL string `json:"url"`
+}
+
+// calendarFeedURL builds the public URL of a feed from the current request
+func calendarFeedURL(c *gin.Context, feed models.CalendarFeed) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/calendar/%s.ics", scheme, c.Request.Host, feed.Token)
+}
+
+// isGroupMemberOrCreator checks if a user belongs to or created a group
+func isGroupMemberOrCreator(db *gorm.DB, userID uint, groupID uint) (bool, error) {
+	var group models.Group
+	if err := db.First(&group, groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if group.CreatedBy == userID {
+		return true, nil
+	}
+	var count int64
+	err := db.Model(&models.UserGroup{}).Where("user_id = ? AND group_id = ?", userID, groupID).Count(&count).Error
+	return count > 0, err
+}
+
+// calendarFeedTasks loads the tasks of a feed, checking that its owner may still see them
+func calendarFeedTasks(db *gorm.DB, feed models.CalendarFeed) ([]models.Task, error) {
+	var tasks []models.Task
+	query := db.Model(&models.Task{})
+
+	switch feed.Scope {
+	case "group":
+		if feed.GroupID == nil {
+			return tasks, nil
+		}
+		member, err := isGroupMemberOrCreator(db, feed.UserID, *feed.GroupID)
+		if err != nil || !member {
+			return tasks, err
+		}
+		// Groups do not grant access to restricted and private tasks
+		assigned := db.Model(&models.AssignTaskToGroup{}).Select("task_id").Where("group_id = ?", *feed.GroupID)
+		followed := db.Model(&models.TaskFollowupGroup{}).Select("task_id").Where("group_id = ?", *feed.GroupID)
+		query = query.Where("(id IN (?) OR id IN (?)) AND confidentiality = ?", assigned, followed, models.ConfidentialityNormal)
+	case "task_type":
+		if feed.TaskTypeID == nil {
+			return tasks, nil
+		}
+		var err error
+		if query, err = myTasksQuery(db, feed.UserID); err != nil {
+			return nil, err
+		}
+		query = query.Where("task_type_id = ?", *feed.TaskTypeID)
+	default:
+		taskIDs, err := myTaskIDs(db, feed.UserID)
+		if err != nil || len(taskIDs) == 0 {
+			return tasks, err
+		}
+		query = query.Where("id IN ?", taskIDs)
+	}
+
+	err := query.Order("start_date, id").Find(&tasks).Error
+	return tasks, err
+}
+
+// icalTaskStatus maps a task status to a VTODO status
+func icalTaskStatus(status string) string {
+	switch status {
+	case "In Progress", "In Review":
+		return "IN-PROCESS"
+	case "Completed":
+		return "COMPLETED"
+	}
+	return "NEEDS-ACTION"
+}
+
+// icalTaskPriority maps a task priority to an iCalendar priority (1 is the highest)
+func icalTaskPriority(priority string) string {
+	switch priority {
+	case "Escalation":
+		return "1"
+	case "High":
+		return "3"
+	case "Medium":
+		return "5"
+	}
+	return "9"
+}
+
+// buildTaskCalendar renders tasks as an iCalendar document
+func buildTaskCalendar(name string, kind string, tasks []models.Task) string {
+	var w utils.ICalWriter
+	w.Line("BEGIN", "VCALENDAR")
+	w.Line("VERSION", "2.0")
+	w.Line("PRODID", "-//taskmanager//Task feed//EN")
+	w.Line("CALSCALE", "GREGORIAN")
+	w.Line("METHOD", "PUBLISH")
+	w.Text("X-WR-CALNAME", name)
+	w.Line("REFRESH-INTERVAL;VALUE=DURATION", fmt.Sprintf("PT%dM", calendarRefreshMinutes))
+	w.Line("X-PUBLISHED-TTL", fmt.Sprintf("PT%dM", calendarRefreshMinutes))
+
+	for _, task := range tasks {
+		component := "VTODO"
+		if kind == "event" {
+			component = "VEVENT"
+		}
+		w.Line("BEGIN", component)
+		w.Line("UID", fmt.Sprintf("task-%d@taskmanager", task.ID))
+		w.Timestamp("DTSTAMP", task.UpdatedAt)
+		w.Timestamp("LAST-MODIFIED", task.UpdatedAt)
+		w.Line("SEQUENCE", fmt.Sprintf("%d", task.Version))
+		w.Text("SUMMARY", task.NotificationLabel())
+		if !task.IsConfidential() && task.Description != "" {
+			w.Text("DESCRIPTION", task.Description)
+		}
+		w.Line("PRIORITY", icalTaskPriority(task.Priority))
+
+		if kind == "event" {
+			// All-day event from the start date through the due date; DTEND is exclusive
+			end := task.StartDate
+			if task.DueDate != nil && task.DueDate.After(end) {
+				end = *task.DueDate
+			}
+			w.Date("DTSTART", task.StartDate)
+			w.Date("DTEND", end.AddDate(0, 0, 1))
+			w.Text("CATEGORIES", task.Status)
+			w.Line("TRANSP", "TRANSPARENT")
+		} else {
+			// DUE must be later than DTSTART, so a task due on its start date only has DUE
+			if task.DueDate == nil || task.DueDate.After(task.StartDate) {
+				w.Date("DTSTART", task.StartDate)
+			}
+			if task.DueDate != nil {
+				w.Date("DUE", *task.DueDate)
+			}
+			w.Line("STATUS", icalTaskStatus(task.Status))
+			w.Line("PERCENT-COMPLETE", fmt.Sprintf("%d", task.Progress))
+		}
+		w.Line("END", component)
+	}
+
+	w.Line("END", "VCALENDAR")
+	return w.String()
+}
+
+// CreateCalendarFeed creates a secret calendar feed URL for the authenticated user
+func CreateCalendarFeed(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var input CreateCalendarFeedInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			_ = ve.Translate(trans)
+			for _, e := range ve {
+				errors = append(errors, e.Translate(trans))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+	if input.Kind == "" {
+		input.Kind = "todo"
+	}
+
+	feed := models.CalendarFeed{UserID: authUserID, Scope: input.Scope, Kind: input.Kind}
+	switch input.Scope {
+	case "group":
+		if input.GroupID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"A group feed requires a group_id"}})
+			return
+		}
+		member, err := isGroupMemberOrCreator(database.DB, authUserID, *input.GroupID)
+		if err != nil || !member {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Group with ID %d not found", *input.GroupID)}})
+			return
+		}
+		feed.GroupID = input.GroupID
+	case "task_type":
+		var taskType models.TaskType
+		if input.TaskTypeID == nil || database.DB.First(&taskType, *input.TaskTypeID).Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid task type ID"}})
+			return
+		}
+		feed.TaskTypeID = input.TaskTypeID
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+	feed.Token = hex.EncodeToString(secret)
+
+	if err := database.DB.Create(&feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": CalendarFeedResponse{CalendarFeed: feed, URL: calendarFeedURL(c, feed)}})
+}
+
+// GetCalendarFeeds lists the calendar feeds of the authenticated user
+func GetCalendarFeeds(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var feeds []models.CalendarFeed
+	if err := database.DB.Where("user_id = ?", authUserID).Order("created_at DESC").Find(&feeds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve calendar feeds"})
+		return
+	}
+
+	response := []CalendarFeedResponse{}
+	for _, feed := range feeds {
+		response = append(response, CalendarFeedResponse{CalendarFeed: feed, URL: calendarFeedURL(c, feed)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": response})
+}
+
+// RevokeCalendarFeed revokes a calendar feed, after which its URL stops working
+func RevokeCalendarFeed(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var feed models.CalendarFeed
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), authUserID).First(&feed).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Calendar feed not found"}})
+		return
+	}
+
+	if err := database.DB.Delete(&feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed revoked successfully"})
+}
+
+// GetCalendarFeed serves a feed as an .ics document. It is public: the token is the secret.
+func GetCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("file"), ".ics")
+
+	var feed models.CalendarFeed
+	if token == "" || database.DB.Where("token = ?", token).First(&feed).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Calendar feed not found"}})
+		return
+	}
+
+	tasks, err := calendarFeedTasks(database.DB, feed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
+		return
+	}
+
+	name := "My tasks"
+	switch feed.Scope {
+	case "group":
+		var group models.Group
+		database.DB.First(&group, feed.GroupID)
+		name = "Group: " + group.Label
+	case "task_type":
+		var taskType models.TaskType
+		database.DB.First(&taskType, feed.TaskTypeID)
+		name = "Task type: " + taskType.Label
+	}
+
+	body := buildTaskCalendar(name, feed.Kind, tasks)
+
+	sum := sha1.Sum([]byte(body))
+	hash := hex.EncodeToString(sum[:])
+	etag := `"` + hash + `"`
+
+	// The task dates cannot tell when a task left the feed, so the feed keeps the date its
+	// document last changed
+	lastModified := feed.CreatedAt
+	if feed.ContentChangedAt != nil {
+		lastModified = *feed.ContentChangedAt
+	}
+	if hash != feed.ContentHash {
+		lastModified = time.Now()
+		if err := database.DB.Model(&feed).UpdateColumns(map[string]interface{}{
+			"content_hash":       hash,
+			"content_changed_at": lastModified,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update calendar feed"})
+			return
+		}
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", calendarRefreshMinutes*60))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if since, err := time.Parse(http.TimeFormat, c.GetHeader("If-Modified-Since")); err == nil && c.GetHeader("If-None-Match") == "" && !lastModified.Truncate(time.Second).After(since) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(body))
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CloneTaskInput selects what a clone copies. Tasks have no checklist, so there is no
+// checklist option.
+type CloneTaskInput struct {
+	Label              string `json:"label" binding:"omitempty,min=3,max=255"`
+	CopyAssignedUsers  bool   `json:"copy_assigned_users"`
+	CopyAssignedGroups bool   `json:"copy_assigned_groups"`
+	CopyFollowUps      bool   `json:"copy_follow_ups"`
+	CopyAttachment     bool   `json:"copy_attachment"`
+	CopyDescription    bool   `json:"copy_description"`
+	ShiftDays          int    `json:"shift_days"`
+	IncludeSubtasks    bool   `json:"include_subtasks"`
+}
+
+// maxCloneTasks caps the size of a cloned subtask tree
+const maxCloneTasks = 200
+
+var errCloneTooLarge = errors.New("clone too large")
+
+// taskCloner copies tasks inside one transaction and collects the notifications to send
+type taskCloner struct {
+	tx              *gorm.DB
+	input           CloneTaskInput
+	authUserID      uint
+	cloned          int
+	skippedSubtasks int // subtasks left out because the caller cannot see them
+	notifications   []models.Notification
+}
+
+// clone copies source as a child of parentID and, when requested, its subtasks after it
+func (cl *taskCloner) clone(source models.Task, parentID *uint, label string) (*models.Task, error) {
+	cl.cloned++
+	if cl.cloned > maxCloneTasks {
+		return nil, errCloneTooLarge
+	}
+
+	sourceID := source.ID
+	task := models.Task{
+		Label:            label,
+		TaskTypeID:       source.TaskTypeID,
+		Priority:         source.Priority,
+		StartDate:        source.StartDate.AddDate(0, 0, cl.input.ShiftDays),
+		Status:           "Pending",
+		Confidentiality:  source.Confidentiality,
+		CreatedBy:        cl.authUserID,
+		OriginalEstimate: source.OriginalEstimate,
+		ParentID:         parentID,
+		ClonedFromID:     &sourceID,
+	}
+	if source.DueDate != nil {
+		dueDate := source.DueDate.AddDate(0, 0, cl.input.ShiftDays)
+		task.DueDate = &dueDate
+	}
+	if source.OriginalEstimate != nil {
+		remaining := *source.OriginalEstimate
+		task.RemainingEstimate = &remaining
+	}
+	if cl.input.CopyDescription {
+		task.Description = source.Description
+	}
+	if cl.input.CopyAttachment {
+		task.Attachment = source.Attachment
+	}
+
+	// Tags and custom field values always come along, so that required fields stay filled
+	if err := cl.tx.Model(&source).Association("Tags").Find(&task.Tags); err != nil {
+		return nil, err
+	}
+	var fieldValues []models.TaskFieldValue
+	if err := cl.tx.Where("task_id = ?", source.ID).Find(&fieldValues).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range fieldValues {
+		task.FieldValues = append(task.FieldValues, models.TaskFieldValue{FieldID: v.FieldID, Value: v.Value, NumberValue: v.NumberValue, DateValue: v.DateValue})
+	}
+
+	if err := cl.tx.Create(&task).Error; err != nil {
+		return nil, err
+	}
+
+	if cl.input.CopyAssignedUsers {
+		var assignments []models.AssignTaskToUser
+		if err := cl.tx.Where("task_id = ?", source.ID).Find(&assignments).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range assignments {
+			if err := cl.tx.Create(&models.AssignTaskToUser{TaskID: task.ID, UserID: a.UserID}).Error; err != nil {
+				return nil, err
+			}
+			cl.notifications = append(cl.notifications, models.Notification{
+				UserID:  a.UserID,
+				TaskID:  &task.ID,
+				Type:    "new_task",
+				Message: fmt.Sprintf("You have been assigned a new task: %s", task.NotificationLabel()),
+			})
+		}
+	}
+
+	if cl.input.CopyAssignedGroups {
+		var assignments []models.AssignTaskToGroup
+		if err := cl.tx.Where("task_id = ?", source.ID).Find(&assignments).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range assignments {
+			if err := cl.tx.Create(&models.AssignTaskToGroup{TaskID: task.ID, GroupID: a.GroupID}).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if cl.input.CopyFollowUps {
+		var followupUsers []models.TaskFollowupUser
+		if err := cl.tx.Where("task_id = ?", source.ID).Find(&followupUsers).Error; err != nil {
+			return nil, err
+		}
+		for _, f := range followupUsers {
+			if err := cl.tx.Create(&models.TaskFollowupUser{TaskID: task.ID, UserID: f.UserID}).Error; err != nil {
+				return nil, err
+			}
+			cl.notifications = append(cl.notifications, models.Notification{
+				UserID:  f.UserID,
+				TaskID:  &task.ID,
+				Type:    "new_task",
+				Message: fmt.Sprintf("You are following a new task: %s", task.NotificationLabel()),
+			})
+		}
+
+		var followupGroups []models.TaskFollowupGroup
+		if err := cl.tx.Where("task_id = ?", source.ID).Find(&followupGroups).Error; err != nil {
+			return nil, err
+		}
+		for _, f := range followupGroups {
+			if err := cl.tx.Create(&models.TaskFollowupGroup{TaskID: task.ID, GroupID: f.GroupID}).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if cl.input.IncludeSubtasks {
+		var subtasks []models.Task
+		if err := cl.tx.Where("parent_id = ?", source.ID).Order("id").Find(&subtasks).Error; err != nil {
+			return nil, err
+		}
+		for _, subtask := range subtasks {
+			// Subtasks the caller cannot see, and theirs, are not copied
+			visible, err := canViewTask(cl.tx, cl.authUserID, subtask)
+			if err != nil {
+				return nil, err
+			}
+			if !visible {
+				cl.skippedSubtasks++
+				continue
+			}
+			clonedSubtask, err := cl.clone(subtask, &task.ID, subtask.Label)
+			if err != nil {
+				return nil, err
+			}
+			task.Subtasks = append(task.Subtasks, *clonedSubtask)
+		}
+	}
+
+	return &task, nil
+}
+
+// CloneTask creates a copy of a task owned by the caller and linked to its source
+func CloneTask(c *gin.Context) {
+	source, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var input CloneTaskInput
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	label := input.Label
+	if label == "" {
+		label = source.Label
+	}
+
+	var clone *models.Task
+	cloner := &taskCloner{input: input, authUserID: authUserID}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		cloner.tx = tx
+		// The top-level clone stays next to its source in the task tree
+		var err error
+		clone, err = cloner.clone(source, source.ParentID, label)
+		return err
+	}); err != nil {
+		if errors.Is(err, errCloneTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("A clone can contain at most %d tasks", maxCloneTasks)}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clone task"})
+		return
+	}
+
+	for _, notification := range cloner.notifications {
+		if notification.UserID == authUserID {
+			continue
+		}
+		if err := database.DB.Create(&notification).Error; err != nil {
+			// Handle error
+		}
+	}
+
+	renderTaskMarkdown(database.DB, authUserID, clone)
+
+	c.JSON(http.StatusCreated, gin.H{"data": clone, "skipped_subtasks": cloner.skippedSubtasks})
+}
@@ -0,0 +1,384 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type UpdateTaskCommentInput struct {
+	Comment                 string `json:"comment" binding:"required,max=10000"`
+	AddMentionedAsFollowups bool   `json:"add_mentioned_as_followups"` // let newly mentioned users who cannot see the task follow it
+}
+
+type AddCommentReactionInput struct {
+	Emoji string `json:"emoji" binding:"required,max=32"`
+}
+
+// defaultCommentPageSize is the number of threads returned with a task
+const defaultCommentPageSize = 20
+
+// isEmoji reports whether s is a single emoji, possibly a sequence with skin tones, variation
+// selectors, zero width joiners or tag characters
+func isEmoji(s string) bool {
+	if s == "" || len(s) > 32 || !utf8.ValidString(s) {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case i == 0 && !unicode.Is(unicode.So, r):
+			return false
+		case unicode.Is(unicode.So, r),
+			r == 0x200D,                  // zero width joiner
+			r >= 0xFE0E && r <= 0xFE0F,   // variation selectors
+			r >= 0x1F3FB && r <= 0x1F3FF, // skin tones
+			r >= 0xE0020 && r <= 0xE007F, // tags of subdivision flags
+			r == 0x20E3:                  // combining keycap
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// resolveCommentParent checks the comment a reply answers. Threads are one level deep: a
+// reply to a reply joins the thread of the comment it answers.
+func resolveCommentParent(db *gorm.DB, taskID uint, parentID *uint) (*uint, string) {
+	if parentID == nil {
+		return nil, ""
+	}
+	var parent models.TaskCommentLog
+	if err := db.Where("id = ? AND task_id = ?", *parentID, taskID).First(&parent).Error; err != nil {
+		return nil, fmt.Sprintf("Comment with ID %d not found on this task", *parentID)
+	}
+	if parent.ParentID != nil {
+		return parent.ParentID, ""
+	}
+	return &parent.ID, ""
+}
+
+// findTaskComment loads a comment that is not deleted, on a task the caller can see
+func findTaskComment(c *gin.Context) (models.TaskCommentLog, models.Task, bool) {
+	var comment models.TaskCommentLog
+	if err := database.DB.Preload("User").First(&comment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Comment not found"}})
+		return comment, models.Task{}, false
+	}
+	task, ok := findVisibleTask(c, strconv.FormatUint(uint64(comment.TaskID), 10))
+	return comment, task, ok
+}
+
+// loadCommentThreads loads a page of the top-level comments of a task, oldest first, with
+// their replies. Deleted comments are included so that they can show as placeholders.
+func loadCommentThreads(db *gorm.DB, taskID uint, page *Pagination) ([]models.TaskCommentLog, error) {
+	query := db.Unscoped().Model(&models.TaskCommentLog{}).Where("task_id = ? AND parent_id IS NULL", taskID)
+	if err := query.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	comments := []models.TaskCommentLog{}
+	err := page.paginate(query).
+		Preload("User").
+		Preload("Replies", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Order("id") }).
+		Preload("Replies.User").
+		Order("id").
+		Find(&comments).Error
+	return comments, err
+}
+
+// presentComments prepares comments and their replies for a reader: deleted comments lose
+// their text for a placeholder, the others get their rendered HTML and reactions
+func presentComments(db *gorm.DB, userID uint, comments []models.TaskCommentLog) {
+	var visible []*models.TaskCommentLog
+	var collect func(list []models.TaskCommentLog)
+	collect = func(list []models.TaskCommentLog) {
+		for i := range list {
+			comment := &list[i]
+			if comment.DeletedAt.Valid {
+				comment.Comment = ""
+				comment.Placeholder = "Comment removed"
+				if comment.RemovedBy != nil && *comment.RemovedBy != comment.UserID {
+					comment.Placeholder = "Comment removed by a moderator"
+				}
+				comment.Reactions = []models.CommentReactionSummary{}
+			} else {
+				visible = append(visible, comment)
+			}
+			collect(comment.Replies)
+		}
+	}
+	collect(comments)
+
+	renderCommentMarkdown(db, userID, visible...)
+	annotateCommentReactions(db, userID, visible...)
+}
+
+// annotateCommentReactions sets the reaction counts of comments for a reader
+func annotateCommentReactions(db *gorm.DB, userID uint, comments ...*models.TaskCommentLog) {
+	if len(comments) == 0 {
+		return
+	}
+	var ids []uint
+	for _, comment := range comments {
+		ids = append(ids, comment.ID)
+	}
+
+	var reactions []models.TaskCommentReaction
+	db.Where("comment_id IN ?", ids).Order("id").Find(&reactions)
+
+	summaries := make(map[uint][]models.CommentReactionSummary)
+	for _, reaction := range reactions {
+		list := summaries[reaction.CommentID]
+		found := false
+		for i := range list {
+			if list[i].Emoji == reaction.Emoji {
+				list[i].Count++
+				list[i].Reacted = list[i].Reacted || reaction.UserID == userID
+				found = true
+			}
+		}
+		if !found {
+			list = append(list, models.CommentReactionSummary{Emoji: reaction.Emoji, Count: 1, Reacted: reaction.UserID == userID})
+		}
+		summaries[reaction.CommentID] = list
+	}
+
+	for _, comment := range comments {
+		comment.Reactions = summaries[comment.ID]
+		if comment.Reactions == nil {
+			comment.Reactions = []models.CommentReactionSummary{}
+		}
+		// Most used first, then in the order they were first used
+		sort.SliceStable(comment.Reactions, func(i, j int) bool {
+			return comment.Reactions[i].Count > comment.Reactions[j].Count
+		})
+	}
+}
+
+// canModerateComment checks that a user may delete someone else's comment: the task
+// creator and super admins can
+func canModerateComment(db *gorm.DB, userID uint, task models.Task) (bool, error) {
+	if task.CreatedBy == userID {
+		return true, nil
+	}
+	return isSuperAdmin(db, userID)
+}
+
+// GetTaskComments lists the comment threads of a task, a page of top-level comments at a time
+func GetTaskComments(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	page, ok := parsePaginationParams(c, "page", "page_size", defaultCommentPageSize)
+	if !ok {
+		return
+	}
+
+	comments, err := loadCommentThreads(database.DB, task.ID, &page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+	presentComments(database.DB, authUserID, comments)
+
+	c.JSON(http.StatusOK, gin.H{"data": comments, "pagination": page})
+}
+
+// UpdateTaskComment edits a comment. Only its author can, and the previous text is kept
+// as a revision.
+func UpdateTaskComment(c *gin.Context) {
+	comment, task, ok := findTaskComment(c)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if comment.UserID != authUserID {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You can only edit your own comments"}})
+		return
+	}
+
+	var input UpdateTaskCommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			_ = ve.Translate(trans)
+			for _, e := range ve {
+				errors = append(errors, e.Translate(trans))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	mentions := MentionResult{Notified: []uint{}}
+	if input.Comment != comment.Comment {
+		previous := comment.Comment
+		now := time.Now()
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			revision := models.TaskCommentRevision{CommentID: comment.ID, Comment: previous, EditedBy: authUserID}
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&comment).Updates(map[string]interface{}{"comment": input.Comment, "edited_at": now}).Error; err != nil {
+				return err
+			}
+
+			// Only mentions added by the edit are notified
+			var err error
+			mentions, err = processMentions(tx, task, authUserID, input.Comment, previous, "a comment on", input.AddMentionedAsFollowups)
+			return err
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+			return
+		}
+		comment.Comment = input.Comment
+		comment.EditedAt = &now
+	}
+
+	renderCommentMarkdown(database.DB, authUserID, &comment)
+	annotateCommentReactions(database.DB, authUserID, &comment)
+
+	c.JSON(http.StatusOK, gin.H{"data": comment, "mentions": mentions})
+}
+
+// GetCommentRevisions lists the previous texts of a comment, latest edit first
+func GetCommentRevisions(c *gin.Context) {
+	comment, _, ok := findTaskComment(c)
+	if !ok {
+		return
+	}
+
+	var revisions []models.TaskCommentRevision
+	if err := database.DB.Preload("Editor").Where("comment_id = ?", comment.ID).Order("id DESC").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comment revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": revisions})
+}
+
+// DeleteTaskComment removes a comment. Authors can remove their own comments, the task
+// creator and super admins any comment. The comment stays in its thread as a placeholder.
+func DeleteTaskComment(c *gin.Context) {
+	comment, task, ok := findTaskComment(c)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if comment.UserID != authUserID {
+		allowed, err := canModerateComment(database.DB, authUserID, task)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to delete this comment"}})
+			return
+		}
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&comment).Update("removed_by", authUserID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&comment).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// respondCommentReactions answers with the current reactions to a comment
+func respondCommentReactions(c *gin.Context, status int, comment models.TaskCommentLog, userID uint) {
+	annotateCommentReactions(database.DB, userID, &comment)
+	c.JSON(status, gin.H{"data": comment.Reactions})
+}
+
+// AddCommentReaction adds an emoji reaction of the caller to a comment. Adding the same
+// reaction twice has no effect.
+func AddCommentReaction(c *gin.Context) {
+	comment, _, ok := findTaskComment(c)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var input AddCommentReactionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+	if !isEmoji(input.Emoji) {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"emoji must be a single emoji"}})
+		return
+	}
+
+	var existing models.TaskCommentReaction
+	err := database.DB.Where("comment_id = ? AND user_id = ? AND emoji = ?", comment.ID, authUserID, input.Emoji).First(&existing).Error
+	if err == nil {
+		respondCommentReactions(c, http.StatusOK, comment, authUserID)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reaction"})
+		return
+	}
+
+	reaction := models.TaskCommentReaction{CommentID: comment.ID, UserID: authUserID, Emoji: input.Emoji}
+	if err := database.DB.Create(&reaction).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reaction"})
+		return
+	}
+
+	respondCommentReactions(c, http.StatusCreated, comment, authUserID)
+}
+
+// RemoveCommentReaction removes an emoji reaction of the caller from a comment
+func RemoveCommentReaction(c *gin.Context) {
+	comment, _, ok := findTaskComment(c)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	result := database.DB.Where("comment_id = ? AND user_id = ? AND emoji = ?", comment.ID, authUserID, c.Param("emoji")).Delete(&models.TaskCommentReaction{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reaction"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Reaction not found"}})
+		return
+	}
+
+	respondCommentReactions(c, http.StatusOK, comment, authUserID)
+}
@@ -0,0 +1,422 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+type CreateTaskTypeFieldInput struct {
+	Key       string   `json:"key" binding:"required,max=100"`
+	Label     string   `json:"label" binding:"required,max=255"`
+	FieldType string   `json:"field_type" binding:"required,oneof=text number date select multi_select user"`
+	Required  bool     `json:"required"`
+	Options   []string `json:"options" binding:"omitempty,dive,required,max=255"`
+	Position  int      `json:"position"`
+}
+
+type UpdateTaskTypeFieldInput struct {
+	Label    string   `json:"label" binding:"omitempty,max=255"`
+	Required *bool    `json:"required"`
+	Options  []string `json:"options" binding:"omitempty,dive,required,max=255"`
+	Position *int     `json:"position"`
+}
+
+// CustomFieldFilter matches tasks by the value of a custom field.
+// Value is an exact match; From and To are inclusive bounds for number and date fields.
+type CustomFieldFilter struct {
+	FieldID uint   `json:"field_id"`
+	Value   string `json:"value"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// requireSuperAdmin writes a 403 response and returns false unless the user is a super admin
+func requireSuperAdmin(c *gin.Context, message string) bool {
+	authUserID := uint(c.MustGet("user_id").(float64))
+	admin, err := isSuperAdmin(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+		return false
+	}
+	if !admin {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{message}})
+		return false
+	}
+	return true
+}
+
+// isEmptyFieldValue reports whether a raw custom field value clears the field
+func isEmptyFieldValue(raw json.RawMessage) bool {
+	s := strings.TrimSpace(string(raw))
+	return s == "" || s == "null" || s == `""` || s == "[]"
+}
+
+// parseCustomFieldValue converts a raw JSON value into stored rows for one field.
+// The returned message uses the same wording as the task input validation errors.
+func parseCustomFieldValue(db *gorm.DB, field models.TaskTypeField, raw json.RawMessage) ([]models.TaskFieldValue, string) {
+	switch field.FieldType {
+	case "text":
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Sprintf("%s must be text", field.Label)
+		}
+		if len(s) > 1000 {
+			return nil, fmt.Sprintf("%s cannot exceed 1000 characters", field.Label)
+		}
+		return []models.TaskFieldValue{{FieldID: field.ID, Value: s}}, ""
+
+	case "number":
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, fmt.Sprintf("%s must be a number", field.Label)
+		}
+		return []models.TaskFieldValue{{FieldID: field.ID, Value: strconv.FormatFloat(n, 'f', -1, 64), NumberValue: &n}}, ""
+
+	case "date":
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Sprintf("%s must be a date in YYYY-MM-DD format", field.Label)
+		}
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, fmt.Sprintf("%s must be a date in YYYY-MM-DD format", field.Label)
+		}
+		return []models.TaskFieldValue{{FieldID: field.ID, Value: s, DateValue: &t}}, ""
+
+	case "select":
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil || !containsString(field.OptionList, s) {
+			return nil, fmt.Sprintf("Invalid %s value. Must be %s", field.Label, joinOptions(field.OptionList))
+		}
+		return []models.TaskFieldValue{{FieldID: field.ID, Value: s}}, ""
+
+	case "multi_select":
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Sprintf("%s must be a list of values", field.Label)
+		}
+		var values []models.TaskFieldValue
+		seen := make(map[string]bool)
+		for _, s := range list {
+			if !containsString(field.OptionList, s) {
+				return nil, fmt.Sprintf("Invalid %s value. Must be %s", field.Label, joinOptions(field.OptionList))
+			}
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			values = append(values, models.TaskFieldValue{FieldID: field.ID, Value: s})
+		}
+		return values, ""
+
+	case "user":
+		var userID uint
+		if err := json.Unmarshal(raw, &userID); err != nil || userID == 0 {
+			return nil, fmt.Sprintf("%s must be a user ID", field.Label)
+		}
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return nil, fmt.Sprintf("User with ID %d not found", userID)
+		}
+		return []models.TaskFieldValue{{FieldID: field.ID, Value: strconv.FormatUint(uint64(userID), 10)}}, ""
+	}
+
+	return nil, fmt.Sprintf("Unsupported field type for %s", field.Label)
+}
+
+// validateCustomFields checks raw custom field values against the fields of a task type.
+// It returns the rows to store for the provided fields and the IDs of those fields, so callers
+// can replace them. Fields listed in existing already have a stored value and satisfy "required".
+func validateCustomFields(db *gorm.DB, taskTypeID uint, raw map[string]json.RawMessage, existing map[uint]bool) ([]models.TaskFieldValue, []uint, []string) {
+	var fields []models.TaskTypeField
+	if err := db.Where("task_type_id = ?", taskTypeID).Order("position, id").Find(&fields).Error; err != nil {
+		return nil, nil, []string{"Failed to load custom fields"}
+	}
+
+	fieldsByKey := make(map[string]models.TaskTypeField)
+	for _, field := range fields {
+		fieldsByKey[field.Key] = field
+	}
+
+	// Sorted so the errors come in the same order on every request
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errors []string
+	for _, key := range keys {
+		if _, ok := fieldsByKey[key]; !ok {
+			errors = append(errors, fmt.Sprintf("Unknown custom field '%s'", key))
+		}
+	}
+
+	var values []models.TaskFieldValue
+	var provided []uint
+	for _, field := range fields {
+		value, ok := raw[field.Key]
+		if !ok || isEmptyFieldValue(value) {
+			if field.Required && (ok || !existing[field.ID]) {
+				errors = append(errors, fmt.Sprintf("%s is required", field.Label))
+			} else if ok {
+				provided = append(provided, field.ID)
+			}
+			continue
+		}
+
+		parsed, message := parseCustomFieldValue(db, field, value)
+		if message != "" {
+			errors = append(errors, message)
+			continue
+		}
+		values = append(values, parsed...)
+		provided = append(provided, field.ID)
+	}
+
+	return values, provided, errors
+}
+
+// applyCustomFieldFilters restricts a task query to tasks whose custom fields match every filter
+func applyCustomFieldFilters(db *gorm.DB, filters []CustomFieldFilter) *gorm.DB {
+	for _, filter := range filters {
+		sub := database.DB.Model(&models.TaskFieldValue{}).Select("task_id").Where("field_id = ?", filter.FieldID)
+		if filter.Value != "" {
+			sub = sub.Where("value = ?", filter.Value)
+		}
+		if filter.From != "" {
+			sub = applyCustomFieldBound(sub, filter.From, ">=")
+		}
+		if filter.To != "" {
+			sub = applyCustomFieldBound(sub, filter.To, "<=")
+		}
+		db = db.Where("tasks.id IN (?)", sub)
+	}
+	return db
+}
+
+// applyCustomFieldBound compares numbers or dates, depending on what the bound looks like
+func applyCustomFieldBound(db *gorm.DB, bound string, operator string) *gorm.DB {
+	if t, err := time.Parse("2006-01-02", bound); err == nil {
+		return db.Where("date_value "+operator+" ?", t)
+	}
+	if n, err := strconv.ParseFloat(bound, 64); err == nil {
+		return db.Where("number_value "+operator+" ?", n)
+	}
+	return db.Where("1 = 0")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// joinOptions formats options like the priority validation message: "A, B, or C"
+func joinOptions(options []string) string {
+	switch len(options) {
+	case 0:
+		return "one of the configured options"
+	case 1:
+		return options[0]
+	case 2:
+		return options[0] + " or " + options[1]
+	}
+	return strings.Join(options[:len(options)-1], ", ") + ", or " + options[len(options)-1]
+}
+
+// CreateTaskTypeField adds a custom field to a task type
+func CreateTaskTypeField(c *gin.Context) {
+	if !requireSuperAdmin(c, "Only a super admin can manage custom fields") {
+		return
+	}
+
+	var taskType models.TaskType
+	if err := database.DB.First(&taskType, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task type not found"}})
+		return
+	}
+
+	var input CreateTaskTypeFieldInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+
+			_ = ve.Translate(trans)
+
+			for _, e := range ve {
+				switch e.Field() {
+				case "Key":
+					if e.Tag() == "required" {
+						errors = append(errors, "Field key is required")
+					} else {
+						errors = append(errors, "Field key cannot exceed 100 characters")
+					}
+				case "Label":
+					if e.Tag() == "required" {
+						errors = append(errors, "Field label is required")
+					} else {
+						errors = append(errors, "Field label cannot exceed 255 characters")
+					}
+				case "FieldType":
+					if e.Tag() == "required" {
+						errors = append(errors, "Field type is required")
+					} else {
+						errors = append(errors, "Invalid field type. Must be text, number, date, select, multi_select, or user")
+					}
+				default:
+					errors = append(errors, e.Translate(trans))
+				}
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	if !customFieldKeyPattern.MatchString(input.Key) {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Field key must start with a letter and contain only lowercase letters, digits and underscores"}})
+		return
+	}
+	isSelect := input.FieldType == "select" || input.FieldType == "multi_select"
+	if isSelect && len(input.Options) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Select fields need at least one option"}})
+		return
+	}
+	if !isSelect {
+		input.Options = nil
+	}
+
+	field := models.TaskTypeField{
+		TaskTypeID: taskType.ID,
+		Key:        input.Key,
+		Label:      input.Label,
+		FieldType:  input.FieldType,
+		Required:   input.Required,
+		OptionList: input.Options,
+		Position:   input.Position,
+	}
+
+	if err := database.DB.Create(&field).Error; err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			c.JSON(http.StatusConflict, gin.H{"errors": []string{"A field with this key already exists on this task type"}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create custom field"})
+		return
+	}
+	if field.OptionList == nil {
+		field.OptionList = []string{}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": field})
+}
+
+// GetTaskTypeFields lists the custom fields of a task type
+func GetTaskTypeFields(c *gin.Context) {
+	var fields []models.TaskTypeField
+	if err := database.DB.Where("task_type_id = ?", c.Param("id")).Order("position, id").Find(&fields).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve custom fields"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": fields})
+}
+
+// UpdateTaskTypeField updates the label, required flag, options or position of a custom field.
+// The key and type cannot change because stored values depend on them.
+func UpdateTaskTypeField(c *gin.Context) {
+	if !requireSuperAdmin(c, "Only a super admin can manage custom fields") {
+		return
+	}
+
+	var field models.TaskTypeField
+	if err := database.DB.First(&field, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Custom field not found"}})
+		return
+	}
+
+	var input UpdateTaskTypeFieldInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	if input.Label != "" {
+		field.Label = input.Label
+	}
+	if input.Required != nil {
+		field.Required = *input.Required
+	}
+	if input.Position != nil {
+		field.Position = *input.Position
+	}
+	if input.Options != nil {
+		if field.FieldType != "select" && field.FieldType != "multi_select" {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Only select fields have options"}})
+			return
+		}
+		if len(input.Options) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Select fields need at least one option"}})
+			return
+		}
+		field.OptionList = input.Options
+	}
+
+	if err := database.DB.Save(&field).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update custom field"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": field})
+}
+
+// DeleteTaskTypeField deletes a custom field and all its values
+func DeleteTaskTypeField(c *gin.Context) {
+	if !requireSuperAdmin(c, "Only a super admin can manage custom fields") {
+		return
+	}
+
+	var field models.TaskTypeField
+	if err := database.DB.First(&field, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Custom field not found"}})
+		return
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("field_id = ?", field.ID).Delete(&models.TaskFieldValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&field).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete custom field"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Custom field deleted successfully"})
+}
@@ -0,0 +1,531 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of tasks loaded at a time while streaming an export
+const exportBatchSize = 500
+
+// exportColumns are the columns an export can have, in their default order
+var exportColumns = []string{
+	"id", "label", "task_type", "priority", "status", "confidentiality", "progress",
+	"start_date", "due_date", "description", "creator", "assignees", "assigned_groups",
+	"follow_up_users", "follow_up_groups", "tags", "comment_count", "external_ref", "parent_id",
+	"original_estimate", "remaining_estimate", "created_at", "updated_at",
+	"started_at", "completed_at", "last_status_change_at",
+}
+
+// defaultExportColumns are exported when no columns are selected
+var defaultExportColumns = []string{
+	"id", "label", "task_type", "priority", "status", "start_date", "due_date",
+	"assignees", "assigned_groups", "comment_count",
+}
+
+// exportWriter writes the rows of an export in one file format
+type exportWriter interface {
+	header(columns []string) error
+	row(columns []string, values []interface{}) error
+	flush() error
+	close() error
+}
+
+type csvExportWriter struct{ w *csv.Writer }
+
+func (e csvExportWriter) header(columns []string) error { return e.w.Write(columns) }
+
+func (e csvExportWriter) row(columns []string, values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatExportValue(value)
+		if text, ok := value.(string); ok {
+			record[i] = csvSafeText(text)
+		}
+	}
+	return e.w.Write(record)
+}
+
+// csvSafeText keeps spreadsheets from evaluating user text as a formula, by prefixing
+// text that starts like one with a quote
+func csvSafeText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (e csvExportWriter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e csvExportWriter) close() error { return e.flush() }
+
+type ndjsonExportWriter struct {
+	w   http.ResponseWriter
+	enc *json.Encoder
+}
+
+func (e ndjsonExportWriter) header(columns []string) error { return nil }
+
+func (e ndjsonExportWriter) row(columns []string, values []interface{}) error {
+	object := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if t, ok := values[i].(time.Time); ok && t.IsZero() {
+			values[i] = nil
+		}
+		object[column] = values[i]
+	}
+	return e.enc.Encode(object)
+}
+
+func (e ndjsonExportWriter) flush() error {
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func (e ndjsonExportWriter) close() error { return e.flush() }
+
+type xlsxExportWriter struct{ x *utils.XLSXWriter }
+
+func (e xlsxExportWriter) header(columns []string) error { return e.x.Header(columns) }
+
+func (e xlsxExportWriter) row(columns []string, values []interface{}) error {
+	return e.x.Row(values)
+}
+
+func (e xlsxExportWriter) flush() error { return e.x.Flush() }
+
+func (e xlsxExportWriter) close() error { return e.x.Close() }
+
+// formatExportValue formats a cell for CSV
+func formatExportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// exportNames maps IDs to names for the label columns of an export
+type exportNames struct {
+	users     map[uint]string
+	groups    map[uint]string
+	taskTypes map[uint]string
+}
+
+func loadExportNames(db *gorm.DB) (exportNames, error) {
+	names := exportNames{users: make(map[uint]string), groups: make(map[uint]string), taskTypes: make(map[uint]string)}
+	var users []models.User
+	if err := db.Select("id", "username").Find(&users).Error; err != nil {
+		return names, err
+	}
+	for _, user := range users {
+		names.users[user.ID] = user.Username
+	}
+	var groups []models.Group
+	if err := db.Select("id", "label").Find(&groups).Error; err != nil {
+		return names, err
+	}
+	for _, group := range groups {
+		names.groups[group.ID] = group.Label
+	}
+	var taskTypes []models.TaskType
+	if err := db.Select("id", "label").Find(&taskTypes).Error; err != nil {
+		return names, err
+	}
+	for _, taskType := range taskTypes {
+		names.taskTypes[taskType.ID] = taskType.Label
+	}
+	return names, nil
+}
+
+// exportBatchStats holds the per-task aggregates of one batch of tasks
+type exportBatchStats struct {
+	comments     map[uint]int64
+	startedAt    map[uint]time.Time
+	completedAt  map[uint]time.Time
+	lastChangeAt map[uint]time.Time
+}
+
+// loadExportBatchStats computes comment counts and status timestamps with one query each
+func loadExportBatchStats(db *gorm.DB, taskIDs []uint) exportBatchStats {
+	stats := exportBatchStats{
+		comments:     make(map[uint]int64),
+		startedAt:    make(map[uint]time.Time),
+		completedAt:  make(map[uint]time.Time),
+		lastChangeAt: make(map[uint]time.Time),
+	}
+
+	var counts []struct {
+		TaskID uint
+		Count  int64
+	}
+	db.Model(&models.TaskCommentLog{}).Select("task_id, COUNT(*) AS count").Where("task_id IN ?", taskIDs).Group("task_id").Scan(&counts)
+	for _, c := range counts {
+		stats.comments[c.TaskID] = c.Count
+	}
+
+	// started_at is the first move to In Progress, completed_at the last move to Completed
+	var times []struct {
+		TaskID      uint
+		StartedAt   *time.Time
+		CompletedAt *time.Time
+		LastChange  *time.Time
+	}
+	db.Model(&models.TaskStatusUpdateLog{}).
+		Select("task_id, MIN(CASE WHEN status = 'In Progress' THEN created_at END) AS started_at, MAX(CASE WHEN status = 'Completed' THEN created_at END) AS completed_at, MAX(created_at) AS last_change").
+		Where("task_id IN ?", taskIDs).
+		Group("task_id").
+		Scan(&times)
+	for _, t := range times {
+		if t.StartedAt != nil {
+			stats.startedAt[t.TaskID] = *t.StartedAt
+		}
+		if t.CompletedAt != nil {
+			stats.completedAt[t.TaskID] = *t.CompletedAt
+		}
+		if t.LastChange != nil {
+			stats.lastChangeAt[t.TaskID] = *t.LastChange
+		}
+	}
+	return stats
+}
+
+// exportValue returns the value of one column of a task. Confidential tasks the caller only
+// sees through their role are exported by ID, without label and description.
+func exportValue(column string, task models.Task, redact bool, names exportNames, stats exportBatchStats) interface{} {
+	joinUsers := func(ids []uint) string {
+		var list []string
+		for _, id := range ids {
+			list = append(list, names.users[id])
+		}
+		return strings.Join(list, "; ")
+	}
+	joinGroups := func(ids []uint) string {
+		var list []string
+		for _, id := range ids {
+			list = append(list, names.groups[id])
+		}
+		return strings.Join(list, "; ")
+	}
+
+	switch column {
+	case "id":
+		return task.ID
+	case "label":
+		if redact {
+			return task.NotificationLabel()
+		}
+		return task.Label
+	case "task_type":
+		return names.taskTypes[task.TaskTypeID]
+	case "priority":
+		return task.Priority
+	case "status":
+		return task.Status
+	case "confidentiality":
+		return task.Confidentiality
+	case "progress":
+		return task.Progress
+	case "start_date":
+		return task.StartDate
+	case "due_date":
+		if task.DueDate == nil {
+			return nil
+		}
+		return *task.DueDate
+	case "description":
+		if redact {
+			return ""
+		}
+		return task.Description
+	case "creator":
+		return names.users[task.CreatedBy]
+	case "assignees":
+		var ids []uint
+		for _, a := range task.AssignedUsers {
+			if a.Status != models.AssignmentDeclined {
+				ids = append(ids, a.UserID)
+			}
+		}
+		return joinUsers(ids)
+	case "assigned_groups":
+		var ids []uint
+		for _, a := range task.AssignedGroups {
+			ids = append(ids, a.GroupID)
+		}
+		return joinGroups(ids)
+	case "follow_up_users":
+		var ids []uint
+		for _, f := range task.FollowupUsers {
+			ids = append(ids, f.UserID)
+		}
+		return joinUsers(ids)
+	case "follow_up_groups":
+		var ids []uint
+		for _, f := range task.FollowupGroups {
+			ids = append(ids, f.GroupID)
+		}
+		return joinGroups(ids)
+	case "tags":
+		var list []string
+		for _, tag := range task.Tags {
+			list = append(list, tag.Label)
+		}
+		return strings.Join(list, "; ")
+	case "comment_count":
+		return stats.comments[task.ID]
+	case "external_ref":
+		if task.ExternalRef == nil {
+			return nil
+		}
+		return *task.ExternalRef
+	case "parent_id":
+		if task.ParentID == nil {
+			return nil
+		}
+		return *task.ParentID
+	case "original_estimate":
+		if task.OriginalEstimate == nil {
+			return nil
+		}
+		return *task.OriginalEstimate
+	case "remaining_estimate":
+		if task.RemainingEstimate == nil {
+			return nil
+		}
+		return *task.RemainingEstimate
+	case "created_at":
+		return task.CreatedAt
+	case "updated_at":
+		return task.UpdatedAt
+	case "started_at":
+		return stats.startedAt[task.ID]
+	case "completed_at":
+		return stats.completedAt[task.ID]
+	case "last_status_change_at":
+		return stats.lastChangeAt[task.ID]
+	}
+	return nil
+}
+
+// exportQuery builds the task query of an export scope:
+//   - my: tasks assigned to or followed by the caller, like GetMyTasks
+//   - created: tasks created by the caller, like GetTasks
+//   - group: tasks assigned to a group the caller belongs to or created
+//   - all: every task the caller can see, for super admins
+//
+// Every scope is restricted to the tasks the caller can see.
+func exportQuery(c *gin.Context, userID uint) (*gorm.DB, bool) {
+	switch c.DefaultQuery("scope", "my") {
+	case "my":
+		taskIDs, err := myTaskIDs(database.DB, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+			return nil, false
+		}
+		if len(taskIDs) == 0 {
+			return database.DB.Model(&models.Task{}).Where("1 = 0"), true
+		}
+		return database.DB.Model(&models.Task{}).Where("id IN ?", taskIDs), true
+
+	case "created":
+		return database.DB.Model(&models.Task{}).Where("created_by = ?", userID), true
+
+	case "group":
+		groupID, err := strconv.ParseUint(c.Query("group_id"), 10, 64)
+		if err != nil || groupID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"The group scope requires a group_id"}})
+			return nil, false
+		}
+		member, err := isGroupMemberOrCreator(database.DB, userID, uint(groupID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Group not found"}})
+			return nil, false
+		}
+		if !member {
+			admin, err := isSuperAdmin(database.DB, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+				return nil, false
+			}
+			if !admin {
+				c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to export the tasks of this group"}})
+				return nil, false
+			}
+		}
+		query, err := visibleTasksQuery(database.DB, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+			return nil, false
+		}
+		assigned := database.DB.Model(&models.AssignTaskToGroup{}).Select("task_id").Where("group_id = ?", groupID)
+		return query.Where("id IN (?)", assigned), true
+
+	case "all":
+		if !requireSuperAdmin(c, "Only super admins can export all tasks") {
+			return nil, false
+		}
+		query, err := visibleTasksQuery(database.DB, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+			return nil, false
+		}
+		return query, true
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid scope. Must be my, created, group, or all"}})
+	return nil, false
+}
+
+// ExportTasks streams the tasks of a scope (see exportQuery) as CSV, XLSX or NDJSON.
+// Query parameters: scope, group_id, format, columns (comma separated, see exportColumns)
+// and view_id to apply the filter of a saved view. Tasks are loaded in batches of
+// ascending ID, so exports of any size run in constant memory.
+func ExportTasks(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid format. Must be csv, xlsx, or ndjson"}})
+		return
+	}
+
+	columns := defaultExportColumns
+	if s := c.Query("columns"); s != "" {
+		columns = nil
+		for _, column := range strings.Split(s, ",") {
+			column = strings.TrimSpace(column)
+			if !containsString(exportColumns, column) {
+				c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Unknown column '%s'. Must be one of %s", column, strings.Join(exportColumns, ", "))}})
+				return
+			}
+			columns = append(columns, column)
+		}
+	}
+
+	query, ok := exportQuery(c, authUserID)
+	if !ok {
+		return
+	}
+
+	if viewID := c.Query("view_id"); viewID != "" {
+		view, ok := findAccessibleView(c, viewID, false)
+		if !ok {
+			return
+		}
+		filter, err := savedViewFilter(view.FilterData)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": []string{"The filter of this view is no longer valid"}})
+			return
+		}
+		query = applyMyTasksFilter(query, filter)
+	}
+
+	names, err := loadExportNames(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export tasks"})
+		return
+	}
+	// Tasks the caller created or is named on are exported in full, even when confidential
+	directIDs, err := myTaskIDs(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+		return
+	}
+	direct := make(map[uint]bool, len(directIDs))
+	for _, id := range directIDs {
+		direct[id] = true
+	}
+
+	filename := fmt.Sprintf("tasks-%s-%s.%s", c.DefaultQuery("scope", "my"), time.Now().Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+
+	var writer exportWriter
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writer = csvExportWriter{w: csv.NewWriter(c.Writer)}
+	case "ndjson":
+		c.Header("Content-Type", "application/x-ndjson")
+		writer = ndjsonExportWriter{w: c.Writer, enc: json.NewEncoder(c.Writer)}
+	case "xlsx":
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		x, err := utils.NewXLSXWriter(c.Writer, "Tasks")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export tasks"})
+			return
+		}
+		writer = xlsxExportWriter{x: x}
+	}
+	c.Status(http.StatusOK)
+
+	// From here on the response has started: failures can only cut the file short
+	if err := writer.header(columns); err != nil {
+		log.Printf("export: %v", err)
+		return
+	}
+
+	var batch []models.Task
+	result := query.
+		Preload("AssignedUsers").
+		Preload("AssignedGroups").
+		Preload("FollowupUsers").
+		Preload("FollowupGroups").
+		Preload("Tags").
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			taskIDs := make([]uint, len(batch))
+			for i, task := range batch {
+				taskIDs[i] = task.ID
+			}
+			stats := loadExportBatchStats(database.DB, taskIDs)
+
+			for _, task := range batch {
+				redact := task.IsConfidential() && task.CreatedBy != authUserID && !direct[task.ID]
+				values := make([]interface{}, len(columns))
+				for i, column := range columns {
+					values[i] = exportValue(column, task, redact, names, stats)
+				}
+				if err := writer.row(columns, values); err != nil {
+					return err
+				}
+			}
+			return writer.flush()
+		})
+	if result.Error != nil {
+		log.Printf("export: %v", result.Error)
+		return
+	}
+
+	if err := writer.close(); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
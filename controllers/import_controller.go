@@ -0,0 +1,660 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const (
+	maxImportRows     = 5000
+	maxImportFileSize = 10 << 20 // bytes
+)
+
+// importColumns are the columns an import file may have, besides "custom.<field key>".
+// Users, groups, task types and tags are given by name; lists are separated by ";" or ",".
+var importColumns = map[string]bool{
+	"external_ref":       true,
+	"label":              true,
+	"task_type":          true,
+	"task_type_id":       true,
+	"priority":           true,
+	"status":             true,
+	"confidentiality":    true,
+	"start_date":         true,
+	"due_date":           true,
+	"description":        true,
+	"assigned_to_users":  true,
+	"assigned_to_groups": true,
+	"follow_up_users":    true,
+	"follow_up_groups":   true,
+	"tags":               true,
+	"original_estimate":  true,
+	"remaining_estimate": true,
+	"parent_id":          true,
+	"parent_ref":         true, // external reference of the parent, which may be an earlier row of the file
+}
+
+// importRow is one row of an import file, keyed by column
+type importRow map[string]string
+
+// parseImportFile reads the rows of a CSV file with a header line or of a JSON array of objects
+func parseImportFile(format string, r io.Reader) ([]importRow, error) {
+	var rows []importRow
+	switch format {
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.TrimLeadingSpace = true
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the CSV header: %v", err)
+		}
+		for i := range header {
+			header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			row := make(importRow)
+			for i, value := range record {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+			rows = append(rows, row)
+			if len(rows) > maxImportRows {
+				break
+			}
+		}
+	case "json":
+		var objects []map[string]interface{}
+		if err := json.NewDecoder(r).Decode(&objects); err != nil {
+			return nil, fmt.Errorf("the file must be a JSON array of objects: %v", err)
+		}
+		for i, object := range objects {
+			row := make(importRow)
+			for key, value := range object {
+				if key == "custom_fields" {
+					fields, ok := value.(map[string]interface{})
+					if !ok {
+						return nil, fmt.Errorf("row %d: custom_fields must be an object", i+1)
+					}
+					for fieldKey, fieldValue := range fields {
+						s, err := importJSONValue(fieldValue)
+						if err != nil {
+							return nil, fmt.Errorf("row %d: custom field %s %v", i+1, fieldKey, err)
+						}
+						row["custom."+fieldKey] = s
+					}
+					continue
+				}
+				s, err := importJSONValue(value)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: %s %v", i+1, key, err)
+				}
+				row[strings.ToLower(key)] = s
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("the file has no rows")
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("the file has more than %d rows", maxImportRows)
+	}
+
+	// Unknown columns are reported once for the file rather than on every row
+	unknown := make(map[string]bool)
+	for _, row := range rows {
+		for column := range row {
+			if !importColumns[column] && !strings.HasPrefix(column, "custom.") {
+				unknown[column] = true
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		var columns []string
+		for column := range unknown {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		return nil, fmt.Errorf("unknown columns: %s", strings.Join(columns, ", "))
+	}
+	return rows, nil
+}
+
+// importJSONValue converts a JSON value to the text form of a CSV cell
+func importJSONValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			s, err := importJSONValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ";"), nil
+	}
+	return "", fmt.Errorf("has an unsupported value")
+}
+
+// splitImportList splits a list cell on semicolons or commas
+func splitImportList(s string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// importResolver turns import rows into task inputs, resolving names to IDs. It keeps the
+// name lookups of the whole file in memory and remembers the external references of the
+// rows seen so far, so that later rows can use them as parent_ref.
+type importResolver struct {
+	db        *gorm.DB
+	userID    uint
+	users     map[string]uint
+	groups    map[string]uint
+	taskTypes map[string]uint
+	tags      map[string]uint
+	fields    map[uint]map[string]models.TaskTypeField
+	refs      map[string]uint // external reference -> task ID, 0 for rows validated in a dry run
+}
+
+func newImportResolver(db *gorm.DB, userID uint) (*importResolver, error) {
+	r := &importResolver{
+		db:        db,
+		userID:    userID,
+		users:     make(map[string]uint),
+		groups:    make(map[string]uint),
+		taskTypes: make(map[string]uint),
+		tags:      make(map[string]uint),
+		fields:    make(map[uint]map[string]models.TaskTypeField),
+		refs:      make(map[string]uint),
+	}
+
+	var users []models.User
+	if err := db.Select("id", "username").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		r.users[strings.ToLower(user.Username)] = user.ID
+	}
+	var groups []models.Group
+	if err := db.Select("id", "label").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		r.groups[strings.ToLower(group.Label)] = group.ID
+	}
+	var taskTypes []models.TaskType
+	if err := db.Select("id", "label").Find(&taskTypes).Error; err != nil {
+		return nil, err
+	}
+	for _, taskType := range taskTypes {
+		r.taskTypes[strings.ToLower(taskType.Label)] = taskType.ID
+	}
+	var tags []models.Tag
+	if err := db.Select("id", "label").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		r.tags[strings.ToLower(tag.Label)] = tag.ID
+	}
+	return r, nil
+}
+
+// names resolves a list cell of names, reporting the unknown ones
+func (r *importResolver) names(cell string, lookup map[string]uint, kind string, errors *[]string) []uint {
+	var ids []uint
+	for _, name := range splitImportList(cell) {
+		id, ok := lookup[strings.ToLower(name)]
+		if !ok {
+			*errors = append(*errors, fmt.Sprintf("Unknown %s '%s'", kind, name))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return uniqueIDs(ids)
+}
+
+// existingTask returns the ID of the task the user already imported with an external reference
+func (r *importResolver) existingTask(ref string) uint {
+	var task models.Task
+	if r.db.Unscoped().Select("id").Where("created_by = ? AND external_ref = ?", r.userID, ref).Limit(1).Find(&task).RowsAffected == 0 {
+		return 0
+	}
+	return task.ID
+}
+
+// customFieldValue encodes a cell as the JSON value the custom field validation expects
+func (r *importResolver) customFieldValue(taskTypeID uint, key string, cell string) (json.RawMessage, error) {
+	if _, ok := r.fields[taskTypeID]; !ok {
+		var fields []models.TaskTypeField
+		if err := r.db.Where("task_type_id = ?", taskTypeID).Find(&fields).Error; err != nil {
+			return nil, err
+		}
+		r.fields[taskTypeID] = make(map[string]models.TaskTypeField)
+		for _, field := range fields {
+			r.fields[taskTypeID][field.Key] = field
+		}
+	}
+
+	var value interface{} = cell
+	switch r.fields[taskTypeID][key].FieldType {
+	case "number":
+		if n, err := strconv.ParseFloat(cell, 64); err == nil {
+			value = n
+		}
+	case "multi_select":
+		value = splitImportList(cell)
+	case "user":
+		if id, ok := r.users[strings.ToLower(cell)]; ok {
+			value = id
+		} else if id, err := strconv.ParseUint(cell, 10, 64); err == nil {
+			value = id
+		}
+	}
+	return json.Marshal(value)
+}
+
+// resolve converts a row into a task input and validates it like CreateTask does
+func (r *importResolver) resolve(row importRow) (CreateTaskInput, []models.Tag, []models.TaskFieldValue, []string) {
+	var errors []string
+	input := CreateTaskInput{
+		Label:           row["label"],
+		Priority:        row["priority"],
+		Status:          row["status"],
+		Confidentiality: row["confidentiality"],
+		Description:     row["description"],
+		ExternalRef:     row["external_ref"],
+		CustomFields:    make(map[string]json.RawMessage),
+	}
+	if input.Priority == "" {
+		input.Priority = "Normal"
+	}
+	if input.Status == "" {
+		input.Status = "Pending"
+	} else if !containsString(taskStatuses, input.Status) {
+		errors = append(errors, "Invalid status value. Must be Pending, In Progress, In Review, or Completed")
+	}
+
+	if name := row["task_type"]; name != "" {
+		if id, ok := r.taskTypes[strings.ToLower(name)]; ok {
+			input.TaskTypeID = id
+		} else {
+			errors = append(errors, fmt.Sprintf("Unknown task type '%s'", name))
+		}
+	} else if s := row["task_type_id"]; s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			errors = append(errors, "Invalid task type ID")
+		}
+		input.TaskTypeID = uint(id)
+	}
+
+	if s := row["start_date"]; s != "" {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			input.StartDate = utils.Date{Time: t}
+		} else {
+			errors = append(errors, "Start date must be in YYYY-MM-DD format")
+		}
+	}
+	if s := row["due_date"]; s != "" {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			input.DueDate = &utils.Date{Time: t}
+		} else {
+			errors = append(errors, "Due date must be in YYYY-MM-DD format")
+		}
+	}
+
+	input.AssignedToUsers = r.names(row["assigned_to_users"], r.users, "user", &errors)
+	input.AssignedToGroups = r.names(row["assigned_to_groups"], r.groups, "group", &errors)
+	input.FollowUpUsers = r.names(row["follow_up_users"], r.users, "user", &errors)
+	input.FollowUpGroups = r.names(row["follow_up_groups"], r.groups, "group", &errors)
+	input.TagIDs = r.names(row["tags"], r.tags, "tag", &errors)
+
+	for column, target := range map[string]**uint{"original_estimate": &input.OriginalEstimate, "remaining_estimate": &input.RemainingEstimate, "parent_id": &input.ParentID} {
+		if s := row[column]; s != "" {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s must be a whole number", column))
+				continue
+			}
+			value := uint(n)
+			*target = &value
+		}
+	}
+	if ref := row["parent_ref"]; ref != "" {
+		if id, ok := r.refs[ref]; ok {
+			if id != 0 {
+				input.ParentID = &id
+			}
+		} else if id := r.existingTask(ref); id != 0 {
+			input.ParentID = &id
+		} else {
+			errors = append(errors, fmt.Sprintf("Parent with external reference '%s' not found", ref))
+		}
+	}
+
+	for column, cell := range row {
+		if key := strings.TrimPrefix(column, "custom."); key != column && cell != "" && input.TaskTypeID != 0 {
+			value, err := r.customFieldValue(input.TaskTypeID, key, cell)
+			if err != nil {
+				errors = append(errors, "Failed to load custom fields")
+				continue
+			}
+			input.CustomFields[key] = value
+		}
+	}
+
+	// The binding rules of CreateTaskInput
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			for _, e := range ve {
+				errors = append(errors, e.Translate(trans))
+			}
+		} else {
+			errors = append(errors, err.Error())
+		}
+	}
+	if len(errors) > 0 {
+		return input, nil, nil, errors
+	}
+
+	tags, fieldValues, errors := validateNewTask(r.db, input, r.userID)
+	return input, tags, fieldValues, errors
+}
+
+// process handles one row: rows whose external reference was imported before are
+// skipped, the others are validated and, unless it is a dry run, created
+func (r *importResolver) process(index int, row importRow, dryRun bool) models.ImportRowResult {
+	result := models.ImportRowResult{Row: index + 1, ExternalRef: row["external_ref"]}
+
+	if ref := result.ExternalRef; ref != "" {
+		if _, seen := r.refs[ref]; seen {
+			result.Status = "failed"
+			result.Errors = []string{fmt.Sprintf("External reference '%s' appears more than once in the file", ref)}
+			return result
+		}
+		if id := r.existingTask(ref); id != 0 {
+			r.refs[ref] = id
+			result.Status = "skipped"
+			result.TaskID = &id
+			return result
+		}
+	}
+
+	input, tags, fieldValues, errors := r.resolve(row)
+	if len(errors) > 0 {
+		result.Status = "failed"
+		result.Errors = errors
+		return result
+	}
+
+	if dryRun {
+		result.Status = "valid"
+	} else {
+		var task models.Task
+		var message string
+		if err := r.db.Transaction(func(tx *gorm.DB) error {
+			if task, message = createTask(tx, input, r.userID, tags, fieldValues); message != "" {
+				return fmt.Errorf("%s", message)
+			}
+			return nil
+		}); err != nil {
+			// A concurrent import of the same reference loses on the unique index of
+			// external references; the task the other import created makes it a skip
+			if ref := result.ExternalRef; ref != "" {
+				if id := r.existingTask(ref); id != 0 {
+					r.refs[ref] = id
+					result.Status = "skipped"
+					result.TaskID = &id
+					return result
+				}
+			}
+			result.Status = "failed"
+			result.Errors = []string{err.Error()}
+			return result
+		}
+		result.Status = "created"
+		result.TaskID = &task.ID
+	}
+	if result.ExternalRef != "" {
+		r.refs[result.ExternalRef] = 0
+		if result.TaskID != nil {
+			r.refs[result.ExternalRef] = *result.TaskID
+		}
+	}
+	return result
+}
+
+// runImportJob imports the rows of a job and records its progress and report
+func runImportJob(db *gorm.DB, job models.ImportJob, rows []importRow) {
+	now := time.Now()
+	db.Model(&job).Updates(map[string]interface{}{"status": models.ImportRunning, "started_at": now})
+
+	results := make([]models.ImportRowResult, 0, len(rows))
+	finish := func(status string, message string) {
+		report, _ := json.Marshal(results)
+		finished := time.Now()
+		db.Model(&job).Updates(map[string]interface{}{
+			"status":         status,
+			"error":          message,
+			"report":         string(report),
+			"processed_rows": job.ProcessedRows,
+			"created_rows":   job.CreatedRows,
+			"skipped_rows":   job.SkippedRows,
+			"failed_rows":    job.FailedRows,
+			"finished_at":    finished,
+		})
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("import job %d: %v", job.ID, p)
+			finish(models.ImportFailed, "The import stopped unexpectedly; importing the file again resumes it")
+		}
+	}()
+
+	resolver, err := newImportResolver(db, job.UserID)
+	if err != nil {
+		finish(models.ImportFailed, "Failed to load users, groups, task types and tags")
+		return
+	}
+
+	for i, row := range rows {
+		result := resolver.process(i, row, false)
+		results = append(results, result)
+		job.ProcessedRows++
+		switch result.Status {
+		case "created":
+			job.CreatedRows++
+		case "skipped":
+			job.SkippedRows++
+		default:
+			job.FailedRows++
+		}
+		db.Model(&job).UpdateColumns(map[string]interface{}{
+			"processed_rows": job.ProcessedRows,
+			"created_rows":   job.CreatedRows,
+			"skipped_rows":   job.SkippedRows,
+			"failed_rows":    job.FailedRows,
+		})
+	}
+
+	finish(models.ImportCompleted, "")
+}
+
+// FailInterruptedImports marks the import jobs left queued or running by a previous run of
+// the server as failed, since jobs run inside the server process and died with it. It is
+// called on startup.
+func FailInterruptedImports(db *gorm.DB) {
+	result := db.Model(&models.ImportJob{}).
+		Where("status IN ?", []string{models.ImportQueued, models.ImportRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ImportFailed,
+			"error":       "The import was interrupted by a server restart; importing the file again resumes it",
+			"finished_at": time.Now(),
+		})
+	if result.Error != nil {
+		log.Printf("failed to mark interrupted imports: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("marked %d interrupted imports as failed", result.RowsAffected)
+	}
+}
+
+// ImportTasks imports tasks from an uploaded CSV or JSON file (form field "file"). With
+// dry_run=true the rows are only validated and the report is returned right away; otherwise
+// the import runs as a background job whose progress is available at GET /imports/:id.
+// Rows with an external_ref that was imported before are skipped, so a file can be imported
+// again safely, for instance after fixing the rows that failed.
+func ImportTasks(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"An import file is required"}})
+		return
+	}
+	if fileHeader.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"errors": []string{fmt.Sprintf("The import file cannot exceed %d MB", maxImportFileSize>>20)}})
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+	}
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid format. Must be csv or json"}})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the import file"})
+		return
+	}
+	defer file.Close()
+
+	rows, err := parseImportFile(format, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid import file: " + err.Error()}})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", c.PostForm("dry_run")))
+	if dryRun {
+		resolver, err := newImportResolver(database.DB, authUserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate the import file"})
+			return
+		}
+		results := make([]models.ImportRowResult, 0, len(rows))
+		counts := map[string]int{"valid": 0, "skipped": 0, "failed": 0}
+		for i, row := range rows {
+			result := resolver.process(i, row, true)
+			counts[result.Status]++
+			results = append(results, result)
+		}
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{
+			"total_rows":   len(rows),
+			"valid_rows":   counts["valid"],
+			"skipped_rows": counts["skipped"],
+			"failed_rows":  counts["failed"],
+			"results":      results,
+		}})
+		return
+	}
+
+	job := models.ImportJob{
+		UserID:    authUserID,
+		Format:    format,
+		FileName:  filepath.Base(fileHeader.Filename),
+		Status:    models.ImportQueued,
+		TotalRows: len(rows),
+	}
+	if err := database.DB.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the import"})
+		return
+	}
+
+	go runImportJob(database.DB, job, rows)
+
+	c.JSON(http.StatusAccepted, gin.H{"data": job})
+}
+
+// GetImportJobs lists the imports of the authenticated user, without their reports
+func GetImportJobs(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var jobs []models.ImportJob
+	if err := database.DB.Omit("report").Where("user_id = ?", authUserID).Order("created_at DESC").Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve imports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+// GetImportJob returns the progress of an import and, once it has finished, its report
+func GetImportJob(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var job models.ImportJob
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), authUserID).First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Import not found"}})
+		return
+	}
+
+	if job.Report != "" {
+		if err := json.Unmarshal([]byte(job.Report), &job.Results); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the import report"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
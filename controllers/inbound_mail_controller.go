@@ -0,0 +1,406 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"taskmanager/database"
+	"taskmanager/mailin"
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type CreateMailSenderInput struct {
+	Address string `json:"address" binding:"required,email,max=191"`
+	UserID  uint   `json:"user_id" binding:"required,gt=0"`
+}
+
+// truncateText shortens text to at most max characters
+func truncateText(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+// ProcessInboundMail is the mailin.Handler of the application. A reply to a task
+// notification, sent to its reply address, becomes a comment on the task; any other
+// message becomes a new task. Every message is logged once, so redeliveries are no-ops.
+func ProcessInboundMail(db *gorm.DB, cfg mailin.Config, msg mailin.Message) error {
+	parsed, err := mailin.Parse(msg.Raw)
+	if err != nil {
+		return mailin.Reject("malformed message: %v", err)
+	}
+
+	var previous models.InboundMail
+	if err := db.Where("message_id = ?", parsed.MessageID).First(&previous).Error; err == nil {
+		if previous.Status == models.InboundMailRejected {
+			return mailin.Reject("%s", previous.Reason)
+		}
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	record := models.InboundMail{
+		MessageID: parsed.MessageID,
+		Sender:    truncateText(parsed.From, 255),
+		Subject:   truncateText(parsed.Subject, 255),
+	}
+	err = handleInboundMail(db, cfg, msg, parsed, &record)
+
+	var reject *mailin.RejectError
+	if errors.As(err, &reject) {
+		record.Status = models.InboundMailRejected
+		record.Reason = truncateText(reject.Reason, 255)
+		if err := db.Create(&record).Error; err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// inboundMailUser finds the active user a sender is mapped to, or whose username is the
+// sender address. It returns nil for unknown senders.
+func inboundMailUser(db *gorm.DB, sender string) (*models.User, error) {
+	var user models.User
+	var mapping models.MailSender
+	err := db.Where("address = ?", sender).First(&mapping).Error
+	switch {
+	case err == nil:
+		err = db.First(&user, mapping.UserID).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = db.Where("LOWER(username) = ?", sender).First(&user).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != 1 {
+		return nil, mailin.Reject("the account of %s is inactive", sender)
+	}
+	return &user, nil
+}
+
+func handleInboundMail(db *gorm.DB, cfg mailin.Config, msg mailin.Message, parsed *mailin.Parsed, record *models.InboundMail) error {
+	if parsed.AutoGenerated {
+		return mailin.Reject("automatic replies are not processed")
+	}
+
+	// The From header is chosen by the sender, so it only identifies a user once the
+	// upstream server authenticated it
+	var user *models.User
+	if cfg.SenderAuthenticated(parsed) {
+		var err error
+		if user, err = inboundMailUser(db, parsed.From); err != nil {
+			return err
+		}
+	}
+	if user != nil {
+		record.UserID = &user.ID
+	} else if !cfg.Allowed(parsed.From) {
+		return mailin.Reject("sender %s is not allowed or could not be authenticated", parsed.From)
+	}
+
+	for _, recipient := range append(msg.Recipients, parsed.Recipients...) {
+		if taskID, userID, ok := cfg.ParseReplyAddress(recipient); ok {
+			record.TaskID = &taskID
+			return commentFromMail(db, parsed, user, taskID, userID, record)
+		}
+	}
+	return taskFromMail(db, cfg, parsed, user, record)
+}
+
+// saveMailAttachments stores the attachments of a message in the uploads directory
+func saveMailAttachments(parsed *mailin.Parsed) ([]string, error) {
+	var paths []string
+	for _, attachment := range parsed.Attachments {
+		path, err := saveUpload(attachment.Filename, attachment.Content)
+		if err != nil {
+			removeMailAttachments(paths)
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// removeMailAttachments deletes the attachments of a message that was not stored, so that
+// redeliveries do not leave copies behind
+func removeMailAttachments(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			log.Printf("mailin: failed to remove attachment %s: %v", path, err)
+		}
+	}
+}
+
+// withAttachmentList appends the names of saved attachments to a text. They are served
+// by GetTaskMailAttachment.
+func withAttachmentList(text string, paths []string) string {
+	if len(paths) == 0 {
+		return text
+	}
+	var b strings.Builder
+	b.WriteString(text)
+	if text != "" {
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Attachments:")
+	for _, path := range paths {
+		b.WriteString("\n- " + filepath.Base(path))
+	}
+	return b.String()
+}
+
+// commentFromMail adds a reply as a comment. The reply address is personal, so it only
+// works for the user it was issued to.
+func commentFromMail(db *gorm.DB, parsed *mailin.Parsed, user *models.User, taskID uint, userID uint, record *models.InboundMail) (err error) {
+	if user == nil {
+		return mailin.Reject("replies to task %d are only accepted from authenticated senders with an account", taskID)
+	}
+	if user.ID != userID {
+		return mailin.Reject("the reply address of task %d belongs to another user", taskID)
+	}
+
+	var task models.Task
+	if err := db.First(&task, taskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mailin.Reject("task %d not found", taskID)
+		}
+		return err
+	}
+	visible, err := canViewTask(db, user.ID, task)
+	if err != nil {
+		return err
+	}
+	allowed, err := canCommentOnTask(db, user.ID, task)
+	if err != nil {
+		return err
+	}
+	if !visible || !allowed {
+		return mailin.Reject("%s is not allowed to comment on task %d", parsed.From, taskID)
+	}
+
+	text := mailin.StripQuotedReply(parsed.Text)
+	if text == "" && len(parsed.Attachments) == 0 {
+		return mailin.Reject("the reply is empty")
+	}
+	paths, err := saveMailAttachments(parsed)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			removeMailAttachments(paths)
+		}
+	}()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		comment, _, err := addTaskComment(tx, task, user.ID, truncateText(withAttachmentList(text, paths), maxCommentLength), nil, false)
+		if err != nil {
+			return err
+		}
+		record.Status = models.InboundMailComment
+		record.CommentID = &comment.ID
+		record.Attachments = strings.Join(paths, "\n")
+		return tx.Create(record).Error
+	})
+}
+
+// taskFromMail creates a task from a message: the subject is the label, the body the
+// description and the first attachment the task attachment. Senders without an account
+// create it as the configured default user.
+func taskFromMail(db *gorm.DB, cfg mailin.Config, parsed *mailin.Parsed, user *models.User, record *models.InboundMail) (err error) {
+	createdBy := cfg.DefaultUserID
+	if user != nil {
+		createdBy = user.ID
+	}
+	if createdBy == 0 {
+		return mailin.Reject("sender %s has no user account", parsed.From)
+	}
+	if cfg.TaskTypeID == 0 {
+		return mailin.Reject("no task type is configured for tasks created by mail")
+	}
+
+	label := parsed.Subject
+	if utf8.RuneCountInString(label) < 3 {
+		label = "Email from " + parsed.From
+	}
+	description := parsed.Text
+	if user == nil {
+		description = fmt.Sprintf("From: %s\n\n%s", parsed.From, description)
+	}
+
+	paths, err := saveMailAttachments(parsed)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			removeMailAttachments(paths)
+		}
+	}()
+
+	now := time.Now()
+	input := CreateTaskInput{
+		Label:       truncateText(label, 255),
+		TaskTypeID:  cfg.TaskTypeID,
+		Priority:    "Normal",
+		Status:      "Pending",
+		StartDate:   utils.Date{Time: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())},
+		Description: truncateText(withAttachmentList(description, paths), maxDescriptionLength),
+	}
+	if len(paths) > 0 {
+		input.Attachment = paths[0]
+	}
+
+	tags, fieldValues, validationErrors := validateNewTask(db, input, createdBy)
+	if len(validationErrors) > 0 {
+		return mailin.Reject("%s", strings.Join(validationErrors, "; "))
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		task, message := createTask(tx, input, createdBy, tags, fieldValues)
+		if message != "" {
+			return errors.New(message)
+		}
+		log.Printf("mailin: created task %d from %s", task.ID, parsed.From)
+		if _, err := processMentions(tx, task, createdBy, task.Description, "", "the description of", false); err != nil {
+			return err
+		}
+		record.Status = models.InboundMailTask
+		record.TaskID = &task.ID
+		record.UserID = &createdBy
+		record.Attachments = strings.Join(paths, "\n")
+		return tx.Create(record).Error
+	})
+}
+
+// GetMailSenders lists the sender to user mappings of inbound mail
+func GetMailSenders(c *gin.Context) {
+	if !requireSuperAdmin(c, "Only super admins can manage mail senders") {
+		return
+	}
+
+	var senders []models.MailSender
+	if err := database.DB.Preload("User").Order("address").Find(&senders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve mail senders"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": senders})
+}
+
+// CreateMailSender maps an email address to a user
+func CreateMailSender(c *gin.Context) {
+	if !requireSuperAdmin(c, "Only super admins can manage mail senders") {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var input CreateMailSenderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			_ = ve.Translate(trans)
+			for _, e := range ve {
+				errors = append(errors, e.Translate(trans))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, input.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("User with ID %d not found", input.UserID)}})
+		return
+	}
+
+	sender := models.MailSender{
+		Address:   strings.ToLower(strings.TrimSpace(input.Address)),
+		UserID:    input.UserID,
+		CreatedBy: authUserID,
+	}
+	var existing int64
+	database.DB.Model(&models.MailSender{}).Where("address = ?", sender.Address).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"errors": []string{"The address is already mapped to a user"}})
+		return
+	}
+	if err := database.DB.Create(&sender).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create mail sender"})
+		return
+	}
+
+	sender.User = &user
+	c.JSON(http.StatusCreated, gin.H{"data": sender})
+}
+
+// DeleteMailSender removes a sender to user mapping
+func DeleteMailSender(c *gin.Context) {
+	if !requireSuperAdmin(c, "Only super admins can manage mail senders") {
+		return
+	}
+
+	var sender models.MailSender
+	if err := database.DB.First(&sender, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Mail sender not found"}})
+		return
+	}
+	if err := database.DB.Delete(&sender).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete mail sender"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Mail sender deleted successfully"})
+}
+
+// GetInboundMails lists the processed and rejected inbound messages, newest first
+func GetInboundMails(c *gin.Context) {
+	if !requireSuperAdmin(c, "Only super admins can view inbound mail") {
+		return
+	}
+
+	pagination, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Model(&models.InboundMail{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&pagination.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inbound mail"})
+		return
+	}
+
+	var mails []models.InboundMail
+	if err := pagination.paginate(query).Order("id DESC").Find(&mails).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inbound mail"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": mails, "pagination": pagination})
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"taskmanager/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// A mention is @ and a username or a group label with spaces written as dashes. It must
+	// not follow a word character, so email addresses are not mentions.
+	mentionPattern = regexp.MustCompile(`(^|[^\w@.])@([A-Za-z0-9][\w.-]*)`)
+	// Mentions inside code are not mentions
+	mentionCodePattern = regexp.MustCompile("(?s)```.*?```|~~~.*?~~~|`[^`\n]*`")
+)
+
+// MentionResult reports how the mentions of a comment or description were handled
+type MentionResult struct {
+	Notified         []uint                `json:"notified"`                     // users who received a mention notification
+	AddedAsFollowups []uint                `json:"added_as_followups,omitempty"` // users added as follow-ups so they can see the task
+	NotVisible       []models.UserResponse `json:"not_visible,omitempty"`        // mentioned users who cannot see the task
+	Warnings         []string              `json:"warnings,omitempty"`
+}
+
+// mentionNames returns the lowercase names mentioned in a Markdown text
+func mentionNames(text string) []string {
+	text = mentionCodePattern.ReplaceAllString(text, " ")
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(strings.TrimRight(m[2], ".-"))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// mentionedUsers resolves mentioned names to active users: usernames first, then group
+// labels, which mention every member of the group
+func mentionedUsers(db *gorm.DB, names []string) ([]models.User, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var users []models.User
+	if err := db.Where("LOWER(username) IN ? AND status = 1", names).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	var groups []models.Group
+	if err := db.Preload("Users").Where("LOWER(REPLACE(label, ' ', '-')) IN ?", names).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	for _, user := range users {
+		seen[user.ID] = true
+	}
+	for _, group := range groups {
+		for _, user := range group.Users {
+			if !seen[user.ID] && user.Status == 1 {
+				seen[user.ID] = true
+				users = append(users, user)
+			}
+		}
+	}
+	return users, nil
+}
+
+// processMentions notifies the users newly mentioned in a comment or description, i.e.
+// mentioned in text but not in previous. Mentioned users who cannot see the task are not
+// notified; when addAsFollowups is set and the author created the task, they are added as
+// follow-up users first, which lets them see it. where describes the text for the
+// notification, e.g. "a comment on".
+func processMentions(db *gorm.DB, task models.Task, authorID uint, text string, previous string, where string, addAsFollowups bool) (MentionResult, error) {
+	result := MentionResult{Notified: []uint{}}
+
+	before := make(map[string]bool)
+	for _, name := range mentionNames(previous) {
+		before[name] = true
+	}
+	var names []string
+	for _, name := range mentionNames(text) {
+		if !before[name] {
+			names = append(names, name)
+		}
+	}
+
+	users, err := mentionedUsers(db, names)
+	if err != nil || len(users) == 0 {
+		return result, err
+	}
+
+	var author models.User
+	db.First(&author, authorID)
+
+	var followupsBefore []uint
+	for _, user := range users {
+		if user.ID == authorID {
+			continue
+		}
+
+		visible, err := canViewTask(db, user.ID, task)
+		if err != nil {
+			return result, err
+		}
+		if !visible && addAsFollowups {
+			if task.CreatedBy != authorID {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Only the task creator can add %s as a follow-up user", user.Username))
+			} else {
+				if followupsBefore == nil {
+					followupsBefore = taskIDSet(db, &models.TaskFollowupUser{}, "user_id", task.ID)
+				}
+				if err := db.Create(&models.TaskFollowupUser{TaskID: task.ID, UserID: user.ID}).Error; err != nil {
+					return result, err
+				}
+				result.AddedAsFollowups = append(result.AddedAsFollowups, user.ID)
+				visible = true
+			}
+		}
+		if !visible {
+			result.NotVisible = append(result.NotVisible, models.UserResponse{ID: user.ID, Username: user.Username, Status: user.Status, UserLabel: user.UserLabel})
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s cannot see this task and was not notified", user.Username))
+			continue
+		}
+
+		notification := models.Notification{
+			UserID:  user.ID,
+			TaskID:  &task.ID,
+			Type:    "mention",
+			Message: fmt.Sprintf("%s mentioned you in %s task '%s'", author.Username, where, task.NotificationLabel()),
+		}
+		if err := db.Create(&notification).Error; err != nil {
+			return result, err
+		}
+		result.Notified = append(result.Notified, user.ID)
+	}
+
+	// Follow-ups added for mentions are part of the task history like any other change
+	if len(result.AddedAsFollowups) > 0 {
+		changes := newTaskChangeLog(task.ID, authorID)
+		changes.addIDSet("follow_up_users", followupsBefore, append(append([]uint{}, followupsBefore...), result.AddedAsFollowups...))
+		if err := changes.save(db); err != nil {
+			return result, err
+		}
+		if err := incrementTaskVersion(db, task.ID); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
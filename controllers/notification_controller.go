@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"taskmanager/database"
+	"taskmanager/mailin"
 	"taskmanager/models"
 )
 
@@ -12,6 +13,15 @@ func GetNotifications(c *gin.Context) {
 	userID := uint(c.MustGet("user_id").(float64))
 	var notifications []models.Notification
 	database.DB.Preload("User").Preload("Task").Where("user_id = ?", userID).Order("created_at desc").Find(&notifications)
+
+	// Replying to the reply address comments on the task
+	if cfg := mailin.LoadConfig(); cfg.Enabled() {
+		for i := range notifications {
+			if notifications[i].TaskID != nil {
+				notifications[i].ReplyTo = cfg.ReplyAddress(*notifications[i].TaskID, userID)
+			}
+		}
+	}
 	c.JSON(http.StatusOK, notifications)
 }
 
@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// savedViewSortColumns are the task columns a view can be sorted by
+var savedViewSortColumns = map[string]bool{
+	"label":      true,
+	"priority":   true,
+	"status":     true,
+	"progress":   true,
+	"start_date": true,
+	"due_date":   true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+type SavedViewInput struct {
+	Name          string          `json:"name" binding:"required,max=100"`
+	Filter        json.RawMessage `json:"filter"` // a GetMyTasksFilterInput
+	Sort          string          `json:"sort" binding:"max=50"`
+	Columns       []string        `json:"columns" binding:"max=30,dive,max=50"`
+	GroupBy       string          `json:"group_by" binding:"omitempty,oneof=status priority task_type"`
+	SharedGroupID *uint           `json:"shared_group_id" binding:"omitempty,gt=0"`
+	IsDefault     bool            `json:"is_default"`
+}
+
+// Pagination describes one page of a paginated list
+type Pagination struct {
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+	Total    int64 `json:"total"`
+}
+
+// parsePagination reads the page and page_size query parameters
+func parsePagination(c *gin.Context) (Pagination, bool) {
+	return parsePaginationParams(c, "page", "page_size", defaultPageSize)
+}
+
+// parsePaginationParams reads a page number and size from the given query parameters
+func parsePaginationParams(c *gin.Context, pageParam string, sizeParam string, defaultSize int) (Pagination, bool) {
+	p := Pagination{Page: 1, PageSize: defaultSize}
+	if s := c.Query(pageParam); s != "" {
+		page, err := strconv.Atoi(s)
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{pageParam + " must be a positive number"}})
+			return p, false
+		}
+		p.Page = page
+	}
+	if s := c.Query(sizeParam); s != "" {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < 1 || size > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{sizeParam + " must be between 1 and " + strconv.Itoa(maxPageSize)}})
+			return p, false
+		}
+		p.PageSize = size
+	}
+	return p, true
+}
+
+// paginate applies the limit and offset of a page to a query
+func (p Pagination) paginate(db *gorm.DB) *gorm.DB {
+	return db.Limit(p.PageSize).Offset((p.Page - 1) * p.PageSize)
+}
+
+// savedViewFilter decodes the filter of a view
+func savedViewFilter(raw json.RawMessage) (GetMyTasksFilterInput, error) {
+	var filter GetMyTasksFilterInput
+	if len(raw) == 0 || string(raw) == "null" {
+		return filter, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&filter)
+	return filter, err
+}
+
+// savedViewOrder turns the grouping and sort of a view into an ORDER BY clause. Tasks are
+// ordered by the grouping column first, so that groups are not split across pages.
+func savedViewOrder(view models.SavedView) string {
+	var order []string
+	switch view.GroupBy {
+	case "status", "priority":
+		order = append(order, view.GroupBy)
+	case "task_type":
+		order = append(order, "task_type_id")
+	}
+	if view.Sort != "" {
+		column := strings.TrimPrefix(view.Sort, "-")
+		if strings.HasPrefix(view.Sort, "-") {
+			order = append(order, column+" DESC")
+		} else {
+			order = append(order, column)
+		}
+	} else {
+		order = append(order, "created_at DESC")
+	}
+	return strings.Join(append(order, "id"), ", ")
+}
+
+// accessibleGroupIDs returns the groups a user belongs to or created
+func accessibleGroupIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	var groupIDs, createdGroupIDs []uint
+	if err := db.Model(&models.UserGroup{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.Group{}).Where("created_by = ?", userID).Pluck("id", &createdGroupIDs).Error; err != nil {
+		return nil, err
+	}
+	return append(groupIDs, createdGroupIDs...), nil
+}
+
+// accessibleViewsQuery returns a query for the user's own views and those shared with their groups
+func accessibleViewsQuery(db *gorm.DB, userID uint) (*gorm.DB, error) {
+	groupIDs, err := accessibleGroupIDs(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(groupIDs) > 0 {
+		return db.Where("user_id = ? OR shared_group_id IN ?", userID, groupIDs), nil
+	}
+	return db.Where("user_id = ?", userID), nil
+}
+
+// findAccessibleView loads a view the authenticated user may use. With ownerOnly, views
+// shared with the user are refused, since only their owner can change them.
+func findAccessibleView(c *gin.Context, id string, ownerOnly bool) (models.SavedView, bool) {
+	var view models.SavedView
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	query, err := accessibleViewsQuery(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+		return view, false
+	}
+	if err := query.Where("id = ?", id).First(&view).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"View not found"}})
+		return view, false
+	}
+	if ownerOnly && view.UserID != authUserID {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"Only the owner can change this view"}})
+		return view, false
+	}
+
+	var defaultView models.DefaultSavedView
+	if database.DB.Where("user_id = ?", authUserID).Limit(1).Find(&defaultView).RowsAffected > 0 {
+		view.IsDefault = defaultView.SavedViewID == view.ID
+	}
+	return view, true
+}
+
+// setDefaultView makes a view the default of a user
+func setDefaultView(db *gorm.DB, userID uint, viewID uint) error {
+	defaultView := models.DefaultSavedView{UserID: userID, SavedViewID: viewID}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"saved_view_id", "updated_at"}),
+	}).Create(&defaultView).Error
+}
+
+// bindSavedViewInput binds and validates a view, and copies it into the given model
+func bindSavedViewInput(c *gin.Context, view *models.SavedView) (SavedViewInput, bool) {
+	var input SavedViewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			_ = ve.Translate(trans)
+			for _, e := range ve {
+				errors = append(errors, e.Translate(trans))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return input, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return input, false
+	}
+
+	if _, err := savedViewFilter(input.Filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid filter: " + err.Error()}})
+		return input, false
+	}
+	if input.Sort != "" && !savedViewSortColumns[strings.TrimPrefix(input.Sort, "-")] {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid sort column"}})
+		return input, false
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if input.SharedGroupID != nil {
+		member, err := isGroupMemberOrCreator(database.DB, authUserID, *input.SharedGroupID)
+		if err != nil || !member {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"You can only share views with your own groups"}})
+			return input, false
+		}
+	}
+
+	view.Name = input.Name
+	view.FilterData = input.Filter
+	if string(input.Filter) == "null" {
+		view.FilterData = nil
+	}
+	view.Sort = input.Sort
+	view.ColumnList = input.Columns
+	view.GroupBy = input.GroupBy
+	view.SharedGroupID = input.SharedGroupID
+	return input, true
+}
+
+// CreateSavedView saves a named view for the authenticated user
+func CreateSavedView(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	view := models.SavedView{UserID: authUserID}
+	input, ok := bindSavedViewInput(c, &view)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&view).Error; err != nil {
+			return err
+		}
+		if input.IsDefault {
+			return setDefaultView(tx, authUserID, view.ID)
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save view"})
+		return
+	}
+
+	view.IsDefault = input.IsDefault
+	c.JSON(http.StatusCreated, gin.H{"data": view})
+}
+
+// GetSavedViews lists the user's own views and the views shared with their groups
+func GetSavedViews(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	query, err := accessibleViewsQuery(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+		return
+	}
+
+	var views []models.SavedView
+	if err := query.Order("name, id").Find(&views).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve views"})
+		return
+	}
+
+	var defaultView models.DefaultSavedView
+	database.DB.Where("user_id = ?", authUserID).Limit(1).Find(&defaultView)
+	for i := range views {
+		views[i].IsDefault = views[i].ID == defaultView.SavedViewID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": views})
+}
+
+// GetSavedViewByID returns a view the user owns or that is shared with them
+func GetSavedViewByID(c *gin.Context) {
+	view, ok := findAccessibleView(c, c.Param("id"), false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// UpdateSavedView replaces the settings of a view. Only the owner can update it.
+func UpdateSavedView(c *gin.Context) {
+	view, ok := findAccessibleView(c, c.Param("id"), true)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	input, ok := bindSavedViewInput(c, &view)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&view).Error; err != nil {
+			return err
+		}
+		if input.IsDefault {
+			return setDefaultView(tx, authUserID, view.ID)
+		}
+		if view.IsDefault {
+			return tx.Where("user_id = ?", authUserID).Delete(&models.DefaultSavedView{}).Error
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update view"})
+		return
+	}
+
+	view.IsDefault = input.IsDefault
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// DeleteSavedView deletes a view. Only the owner can delete it.
+func DeleteSavedView(c *gin.Context) {
+	view, ok := findAccessibleView(c, c.Param("id"), true)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// The view stops being the default of everybody who used it
+		if err := tx.Where("saved_view_id = ?", view.ID).Delete(&models.DefaultSavedView{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&view).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete view"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "View deleted successfully"})
+}
+
+// SetDefaultSavedView makes a view, own or shared, the default of the authenticated user
+func SetDefaultSavedView(c *gin.Context) {
+	view, ok := findAccessibleView(c, c.Param("id"), false)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if err := setDefaultView(database.DB, authUserID, view.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set default view"})
+		return
+	}
+
+	view.IsDefault = true
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// ClearDefaultSavedView removes the default view of the authenticated user
+func ClearDefaultSavedView(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	if err := database.DB.Where("user_id = ?", authUserID).Delete(&models.DefaultSavedView{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear default view"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Default view cleared successfully"})
+}
+
+// GetSavedViewTasks runs a view against the tasks assigned to or followed by the
+// authenticated user, one page at a time. A shared view shows each user their own tasks.
+func GetSavedViewTasks(c *gin.Context) {
+	view, ok := findAccessibleView(c, c.Param("id"), false)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	filter, err := savedViewFilter(view.FilterData)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": []string{"The filter of this view is no longer valid"}})
+		return
+	}
+
+	relevantTaskIDs, err := myTaskIDs(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+		return
+	}
+
+	tasks := []models.Task{}
+	if len(relevantTaskIDs) > 0 {
+		query := applyMyTasksFilter(database.DB.Model(&models.Task{}).Where("id IN ?", relevantTaskIDs), filter)
+		if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
+			return
+		}
+
+		if err := page.paginate(query).
+			Preload("AssignedUsers.User").
+			Preload("AssignedGroups.Group.Users").
+			Preload("FollowupUsers.User").
+			Preload("FollowupGroups.Group.Users").
+			Preload("Creator").
+			Preload("Tags").
+			Preload("FieldValues.Field").
+			Order(savedViewOrder(view)).
+			Find(&tasks).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
+			return
+		}
+	}
+
+	annotateReadState(database.DB, authUserID, tasks)
+	renderTasksMarkdown(database.DB, authUserID, tasks)
+
+	c.JSON(http.StatusOK, gin.H{"data": tasks, "view": view, "pagination": page})
+}
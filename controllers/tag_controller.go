@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+type CreateTagInput struct {
+	Label string `json:"label" binding:"required,min=1,max=100"`
+	Color string `json:"color" binding:"omitempty,hexcolor"`
+}
+
+type UpdateTagInput struct {
+	Label string `json:"label" binding:"omitempty,min=1,max=100"`
+	Color string `json:"color" binding:"omitempty,hexcolor"`
+}
+
+type MergeTagInput struct {
+	IntoTagID uint `json:"into_tag_id" binding:"required,gt=0"`
+}
+
+// findTags loads the tags with the given IDs and fails if any of them does not exist
+func findTags(db *gorm.DB, tagIDs []uint) ([]models.Tag, error) {
+	var tags []models.Tag
+	if len(tagIDs) == 0 {
+		return tags, nil
+	}
+	if err := db.Where("id IN ?", tagIDs).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[uint]bool)
+	for _, tag := range tags {
+		found[tag.ID] = true
+	}
+	for _, id := range tagIDs {
+		if !found[id] {
+			return nil, fmt.Errorf("Tag with ID %d not found", id)
+		}
+	}
+	return tags, nil
+}
+
+// applyTagFilter restricts a task query to tasks carrying any or all of the given tags
+func applyTagFilter(db *gorm.DB, tagIDs []uint, match string) *gorm.DB {
+	if len(tagIDs) == 0 {
+		return db
+	}
+	// The subqueries share the session of the query, e.g. its transaction, but not its conditions
+	session := db.Session(&gorm.Session{NewDB: true})
+	if match == "all" {
+		return db.Where("tasks.id IN (?)", session.Table("task_tags").
+			Select("task_id").
+			Where("tag_id IN ?", tagIDs).
+			Group("task_id").
+			Having("COUNT(DISTINCT tag_id) = ?", len(uniqueIDs(tagIDs))))
+	}
+	return db.Where("tasks.id IN (?)", session.Table("task_tags").Select("task_id").Where("tag_id IN ?", tagIDs))
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool)
+	var unique []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// canManageTag checks that the user created the tag or is a super admin
+func canManageTag(c *gin.Context, tag models.Tag) bool {
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if tag.CreatedBy == authUserID {
+		return true
+	}
+	admin, err := isSuperAdmin(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+		return false
+	}
+	if !admin {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to change this tag"}})
+		return false
+	}
+	return true
+}
+
+// CreateTag creates a new tag
+func CreateTag(c *gin.Context) {
+	var input CreateTagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+
+			_ = ve.Translate(trans)
+
+			for _, e := range ve {
+				if e.Field() == "Label" && e.Tag() == "required" {
+					errors = append(errors, "Tag label is required")
+				} else if e.Field() == "Label" && e.Tag() == "max" {
+					errors = append(errors, "Tag label cannot exceed 100 characters")
+				} else if e.Field() == "Color" && e.Tag() == "hexcolor" {
+					errors = append(errors, "Tag color must be a hex color such as #ff0000")
+				} else {
+					errors = append(errors, e.Translate(trans))
+				}
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	tag := models.Tag{Label: input.Label, Color: input.Color, CreatedBy: uint(c.MustGet("user_id").(float64))}
+	if tag.Color == "" {
+		tag.Color = "#6b7280"
+	}
+
+	if err := database.DB.Create(&tag).Error; err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			c.JSON(http.StatusConflict, gin.H{"errors": []string{"Tag with this label already exists"}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": tag})
+}
+
+// GetTags lists tags with their usage counts, most used first.
+// The optional "q" query parameter filters by label prefix for autocomplete.
+func GetTags(c *gin.Context) {
+	db := database.DB.Model(&models.Tag{}).
+		Select("tags.id, tags.label, tags.color, tags.created_by, COUNT(tasks.id) AS usage_count").
+		Joins("LEFT JOIN task_tags ON task_tags.tag_id = tags.id").
+		Joins("LEFT JOIN tasks ON tasks.id = task_tags.task_id AND tasks.deleted_at IS NULL").
+		Group("tags.id, tags.label, tags.color, tags.created_by").
+		Order("usage_count DESC, tags.label ASC")
+
+	if q := c.Query("q"); q != "" {
+		db = db.Where("tags.label LIKE ?", likeEscaper.Replace(q)+"%")
+	}
+
+	var tags []models.TagResponse
+	if err := db.Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+	if tags == nil {
+		tags = []models.TagResponse{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// UpdateTag renames or recolors a tag
+func UpdateTag(c *gin.Context) {
+	var tag models.Tag
+	if err := database.DB.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Tag not found"}})
+		return
+	}
+
+	if !canManageTag(c, tag) {
+		return
+	}
+
+	var input UpdateTagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	if err := database.DB.Model(&tag).Updates(models.Tag{Label: input.Label, Color: input.Color}).Error; err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			c.JSON(http.StatusConflict, gin.H{"errors": []string{"Tag with this label already exists"}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tag})
+}
+
+// MergeTag moves every task of a tag to another tag and deletes the merged tag
+func MergeTag(c *gin.Context) {
+	var tag models.Tag
+	if err := database.DB.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Tag not found"}})
+		return
+	}
+
+	if !canManageTag(c, tag) {
+		return
+	}
+
+	var input MergeTagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	if input.IntoTagID == tag.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"A tag cannot be merged into itself"}})
+		return
+	}
+
+	var target models.Tag
+	if err := database.DB.First(&target, input.IntoTagID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Target tag not found"}})
+		return
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Drop links that would become duplicates once moved to the target tag
+		if err := tx.Exec(
+			"DELETE FROM task_tags WHERE tag_id = ? AND task_id IN (SELECT task_id FROM (SELECT task_id FROM task_tags WHERE tag_id = ?) AS target_tasks)",
+			tag.ID, target.ID,
+		).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE task_tags SET tag_id = ? WHERE tag_id = ?", target.ID, tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": target})
+}
+
+// DeleteTag deletes a tag and removes it from all tasks
+func DeleteTag(c *gin.Context) {
+	var tag models.Tag
+	if err := database.DB.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Tag not found"}})
+		return
+	}
+
+	if !canManageTag(c, tag) {
+		return
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM task_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
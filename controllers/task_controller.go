@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -17,48 +18,72 @@ import (
 )
 
 type CreateTaskInput struct {
-	Label            string      `json:"label" binding:"required,min=3,max=255"`
-	TaskTypeID       uint        `json:"task_type_id" binding:"required,gt=0"`
-	Priority         string      `json:"priority" binding:"required,oneof=Normal Medium High Escalation"`
-	StartDate        utils.Date  `json:"start_date" binding:"required"`
-	DueDate          *utils.Date `json:"due_date" binding:"omitempty,gtefield=StartDate"`
-	Description      string      `json:"description"`
-	Attachment       string      `json:"attachment"`
-	Status           string      `json:"status"`
-	AssignedToUsers  []uint      `json:"assigned_to_users" binding:"omitempty,dive,gt=0"`
-	AssignedToGroups []uint      `json:"assigned_to_groups" binding:"omitempty,dive,gt=0"`
-	FollowUpUsers    []uint      `json:"follow_up_users" binding:"omitempty,dive,gt=0"`
-	FollowUpGroups   []uint      `json:"follow_up_groups" binding:"omitempty,dive,gt=0"`
+	Label                   string                     `json:"label" binding:"required,min=3,max=255"`
+	TaskTypeID              uint                       `json:"task_type_id" binding:"required,gt=0"`
+	Priority                string                     `json:"priority" binding:"required,oneof=Normal Medium High Escalation"`
+	StartDate               utils.Date                 `json:"start_date" binding:"required"`
+	DueDate                 *utils.Date                `json:"due_date" binding:"omitempty,gtefield=StartDate"`
+	Description             string                     `json:"description" binding:"max=50000"`
+	Attachment              string                     `json:"attachment"`
+	Status                  string                     `json:"status"`
+	Confidentiality         string                     `json:"confidentiality" binding:"omitempty,oneof=normal restricted private"`
+	AssignedToUsers         []uint                     `json:"assigned_to_users" binding:"omitempty,dive,gt=0"`
+	AssignedToGroups        []uint                     `json:"assigned_to_groups" binding:"omitempty,dive,gt=0"`
+	FollowUpUsers           []uint                     `json:"follow_up_users" binding:"omitempty,dive,gt=0"`
+	FollowUpGroups          []uint                     `json:"follow_up_groups" binding:"omitempty,dive,gt=0"`
+	OriginalEstimate        *uint                      `json:"original_estimate"`  // minutes
+	RemainingEstimate       *uint                      `json:"remaining_estimate"` // minutes
+	ParentID                *uint                      `json:"parent_id" binding:"omitempty,gt=0"`
+	TagIDs                  []uint                     `json:"tag_ids" binding:"omitempty,dive,gt=0"`
+	CustomFields            map[string]json.RawMessage `json:"custom_fields"`                  // keyed by field key
+	ExternalRef             string                     `json:"external_ref" binding:"max=191"` // ID of the task in the system it was imported from
+	AddMentionedAsFollowups bool                       `json:"add_mentioned_as_followups"`     // let mentioned users who cannot see the task follow it
 }
 
 type UpdateTaskInput struct {
-	Label            string      `json:"label" binding:"min=3,max=255"`
-	TaskTypeID       uint        `json:"task_type_id" binding:"gt=0"`
-	Priority         string      `json:"priority" binding:"oneof=Normal Medium High Escalation"`
-	StartDate        utils.Date  `json:"start_date" binding:"required"`
-	DueDate          *utils.Date `json:"due_date" binding:"omitempty,gtefield=StartDate"`
-	Description      string      `json:"description"`
-	Attachment       string      `json:"attachment"`
-	Status           string      `json:"status"`
-	AssignedToUsers  []uint      `json:"assigned_to_users" binding:"omitempty,dive,gt=0"`
-	AssignedToGroups []uint      `json:"assigned_to_groups" binding:"omitempty,dive,gt=0"`
-	FollowUpUsers    []uint      `json:"follow_up_users" binding:"omitempty,dive,gt=0"`
-	FollowUpGroups   []uint      `json:"follow_up_groups" binding:"omitempty,dive,gt=0"`
+	Label                   string                     `json:"label" binding:"min=3,max=255"`
+	TaskTypeID              uint                       `json:"task_type_id" binding:"gt=0"`
+	Priority                string                     `json:"priority" binding:"oneof=Normal Medium High Escalation"`
+	StartDate               utils.Date                 `json:"start_date" binding:"required"`
+	DueDate                 *utils.Date                `json:"due_date" binding:"omitempty,gtefield=StartDate"`
+	Description             string                     `json:"description" binding:"max=50000"`
+	Attachment              string                     `json:"attachment"`
+	Status                  string                     `json:"status"`
+	Confidentiality         string                     `json:"confidentiality" binding:"omitempty,oneof=normal restricted private"`
+	AssignedToUsers         []uint                     `json:"assigned_to_users" binding:"omitempty,dive,gt=0"`
+	AssignedToGroups        []uint                     `json:"assigned_to_groups" binding:"omitempty,dive,gt=0"`
+	FollowUpUsers           []uint                     `json:"follow_up_users" binding:"omitempty,dive,gt=0"`
+	FollowUpGroups          []uint                     `json:"follow_up_groups" binding:"omitempty,dive,gt=0"`
+	OriginalEstimate        *uint                      `json:"original_estimate"`  // minutes
+	RemainingEstimate       *uint                      `json:"remaining_estimate"` // minutes
+	ParentID                *uint                      `json:"parent_id" binding:"omitempty,gt=0"`
+	TagIDs                  []uint                     `json:"tag_ids" binding:"omitempty,dive,gt=0"`
+	CustomFields            map[string]json.RawMessage `json:"custom_fields"`              // keyed by field key
+	Version                 *uint                      `json:"version"`                    // alternative to the If-Match header
+	AddMentionedAsFollowups bool                       `json:"add_mentioned_as_followups"` // let newly mentioned users who cannot see the task follow it
 }
 
 type UpdateTaskStatusInput struct {
-	Status string `json:"status" binding:"required,oneof=Pending 'In Progress' 'In Review' Completed"`
+	Status   string `json:"status" binding:"required,oneof=Pending 'In Progress' 'In Review' Completed"`
+	Progress *uint  `json:"progress" binding:"omitempty,lte=100"` // percent
+	Note     string `json:"note" binding:"max=2000"`
+	Version  *uint  `json:"version"` // alternative to the If-Match header
 }
 
 type AddTaskCommentInput struct {
-	Comment string `json:"comment" binding:"required"`
+	Comment                 string `json:"comment" binding:"required,max=10000"`
+	ParentID                *uint  `json:"parent_id" binding:"omitempty,gt=0"` // the comment this one replies to
+	AddMentionedAsFollowups bool   `json:"add_mentioned_as_followups"`         // let mentioned users who cannot see the task follow it
 }
 
 type GetMyTasksFilterInput struct {
-	FromDate   *utils.Date `json:"from_date"`
-	ToDate     *utils.Date `json:"to_date"`
-	Status     string      `json:"status"`
-	TaskTypeID uint        `json:"task_type_id"`
+	FromDate     *utils.Date         `json:"from_date"`
+	ToDate       *utils.Date         `json:"to_date"`
+	Status       string              `json:"status"`
+	TaskTypeID   uint                `json:"task_type_id"`
+	TagIDs       []uint              `json:"tag_ids"`
+	TagMatch     string              `json:"tag_match"` // "any" (default) or "all"
+	CustomFields []CustomFieldFilter `json:"custom_fields"`
 }
 
 // CreateTask creates a new task
@@ -113,68 +138,156 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	tags, fieldValues, errors := validateNewTask(database.DB, input, authUserID)
+	if len(errors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	// The task and the follow-ups added for its mentions are created together
+	var task models.Task
+	var mentions MentionResult
+	message := "Failed to notify mentioned users"
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var failure string
+		if task, failure = createTask(tx, input, authUserID, tags, fieldValues); failure != "" {
+			message = failure
+			return fmt.Errorf("%s", failure)
+		}
+		var err error
+		mentions, err = processMentions(tx, task, authUserID, task.Description, "", "the description of", input.AddMentionedAsFollowups)
+		return err
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+		return
+	}
+	if len(mentions.AddedAsFollowups) > 0 {
+		database.DB.Preload("User").Where("task_id = ?", task.ID).Find(&task.FollowupUsers)
+		database.DB.Model(&models.Task{}).Where("id = ?", task.ID).Pluck("version", &task.Version)
+	}
+
+	renderTaskMarkdown(database.DB, authUserID, &task)
+
+	c.JSON(http.StatusCreated, gin.H{"data": task, "mentions": mentions})
+}
+
+// validateNewTask checks the references of a new task: task type, parent, tags, custom
+// fields, assignees and follow-ups. It returns the tags and custom field rows to store.
+func validateNewTask(db *gorm.DB, input CreateTaskInput, createdBy uint) ([]models.Tag, []models.TaskFieldValue, []string) {
 	// Check if the task type exists
 	var taskType models.TaskType
-	if err := database.DB.First(&taskType, input.TaskTypeID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid task type ID"}})
-		return
+	if err := db.First(&taskType, input.TaskTypeID).Error; err != nil {
+		return nil, nil, []string{"Invalid task type ID"}
+	}
+
+	if !validAttachment(input.Attachment) {
+		return nil, nil, []string{"Attachment must be a file uploaded with /upload-attachment"}
+	}
+
+	// Validate ParentID, if provided
+	if input.ParentID != nil {
+		var parent models.Task
+		if err := db.First(&parent, *input.ParentID).Error; err != nil {
+			return nil, nil, []string{"Parent task not found"}
+		}
+	}
+
+	// Validate TagIDs
+	tags, err := findTags(db, input.TagIDs)
+	if err != nil {
+		return nil, nil, []string{err.Error()}
+	}
+
+	// Validate CustomFields
+	fieldValues, _, fieldErrors := validateCustomFields(db, input.TaskTypeID, input.CustomFields, nil)
+	if len(fieldErrors) > 0 {
+		return nil, nil, fieldErrors
 	}
 
 	// Validate AssignedToUsers
 	for _, userID := range input.AssignedToUsers {
 		var user models.User
-		if err := database.DB.First(&user, userID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("User with ID %d not found", userID)}})
-			return
+		if err := db.First(&user, userID).Error; err != nil {
+			return nil, nil, []string{fmt.Sprintf("User with ID %d not found", userID)}
 		}
 	}
 
 	// Validate AssignedToGroups
 	for _, groupID := range input.AssignedToGroups {
 		var group models.Group
-		if err := database.DB.First(&group, groupID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Group with ID %d not found", groupID)}})
-			return
+		if err := db.First(&group, groupID).Error; err != nil {
+			return nil, nil, []string{fmt.Sprintf("Group with ID %d not found", groupID)}
 		}
 	}
 
 	// Validate FollowUpUsers
 	for _, userID := range input.FollowUpUsers {
 		var user models.User
-		if err := database.DB.First(&user, userID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Follow-up user with ID %d not found", userID)}})
-			return
+		if err := db.First(&user, userID).Error; err != nil {
+			return nil, nil, []string{fmt.Sprintf("Follow-up user with ID %d not found", userID)}
 		}
 	}
 
 	// Validate FollowUpGroups
 	for _, groupID := range input.FollowUpGroups {
 		var group models.Group
-		if err := database.DB.First(&group, groupID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Follow-up group with ID %d not found", groupID)}})
-			return
+		if err := db.First(&group, groupID).Error; err != nil {
+			return nil, nil, []string{fmt.Sprintf("Follow-up group with ID %d not found", groupID)}
+		}
+	}
+
+	// External references identify imported tasks, so they are unique per creator
+	if input.ExternalRef != "" {
+		var existing int64
+		db.Unscoped().Model(&models.Task{}).Where("created_by = ? AND external_ref = ?", createdBy, input.ExternalRef).Count(&existing)
+		if existing > 0 {
+			return nil, nil, []string{fmt.Sprintf("A task with external reference '%s' already exists", input.ExternalRef)}
 		}
 	}
 
+	return tags, fieldValues, nil
+}
+
+// createTask stores a validated new task with its assignments and follow-ups and notifies
+// the users involved. The returned message describes the step that failed.
+func createTask(db *gorm.DB, input CreateTaskInput, createdBy uint, tags []models.Tag, fieldValues []models.TaskFieldValue) (models.Task, string) {
 	task := models.Task{
-		Label:       input.Label,
-		TaskTypeID:  input.TaskTypeID,
-		Priority:    input.Priority,
-		StartDate:   input.StartDate.Time,
-		Description: input.Description,
-		Attachment:  input.Attachment,
-		Status:      input.Status,
-		CreatedBy:   uint(c.MustGet("user_id").(float64)),
+		Label:             input.Label,
+		TaskTypeID:        input.TaskTypeID,
+		Priority:          input.Priority,
+		StartDate:         input.StartDate.Time,
+		Description:       input.Description,
+		Attachment:        input.Attachment,
+		Status:            input.Status,
+		CreatedBy:         createdBy,
+		Confidentiality:   input.Confidentiality,
+		OriginalEstimate:  input.OriginalEstimate,
+		RemainingEstimate: input.RemainingEstimate,
+		ParentID:          input.ParentID,
+		Tags:              tags,
+		FieldValues:       fieldValues,
 	}
 
 	if input.DueDate != nil {
 		task.DueDate = &input.DueDate.Time
 	}
+	if input.ExternalRef != "" {
+		task.ExternalRef = &input.ExternalRef
+	}
+	if task.Confidentiality == "" {
+		task.Confidentiality = models.ConfidentialityNormal
+	}
 
-	
-	if err := database.DB.Create(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
-		return
+	// Remaining estimate starts out equal to the original estimate unless given
+	if task.RemainingEstimate == nil && task.OriginalEstimate != nil {
+		remaining := *task.OriginalEstimate
+		task.RemainingEstimate = &remaining
+	}
+
+	if err := db.Create(&task).Error; err != nil {
+		return task, "Failed to create task"
 	}
 
 	// Assign task to users
@@ -183,21 +296,20 @@ func CreateTask(c *gin.Context) {
 			TaskID: task.ID,
 			UserID: userID,
 		}
-		if err := database.DB.Create(&assignToUser).Error; err != nil {
+		if err := db.Create(&assignToUser).Error; err != nil {
 			// Handle error, perhaps rollback task creation or log it
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign task to user"})
-			return
+			return task, "Failed to assign task to user"
 		}
 
 		// Create notification for assigned user
 		var notification models.Notification
 		notification = models.Notification{
 			UserID:  userID,
-			TaskID:  task.ID,
+			TaskID:  &task.ID,
 			Type:    "new_task",
-			Message: fmt.Sprintf("You have been assigned a new task: %s", task.Label),
+			Message: fmt.Sprintf("You have been assigned a new task: %s", task.NotificationLabel()),
 		}
-		if err := database.DB.Create(&notification).Error; err != nil {
+		if err := db.Create(&notification).Error; err != nil {
 			// Handle error
 		}
 	}
@@ -208,10 +320,9 @@ func CreateTask(c *gin.Context) {
 			TaskID:  task.ID,
 			GroupID: groupID,
 		}
-		if err := database.DB.Create(&assignToGroup).Error; err != nil {
+		if err := db.Create(&assignToGroup).Error; err != nil {
 			// Handle error, perhaps rollback task creation or log it
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign task to group"})
-			return
+			return task, "Failed to assign task to group"
 		}
 	}
 
@@ -221,20 +332,19 @@ func CreateTask(c *gin.Context) {
 			TaskID: task.ID,
 			UserID: userID,
 		}
-		if err := database.DB.Create(&followUpUser).Error; err != nil {
+		if err := db.Create(&followUpUser).Error; err != nil {
 			// Handle error, perhaps rollback task creation or log it
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign task to follow-up user"})
-			return
+			return task, "Failed to assign task to follow-up user"
 		}
 
 		// Create notification for follow-up user
 		notification := models.Notification{
 			UserID:  userID,
-			TaskID:  task.ID,
+			TaskID:  &task.ID,
 			Type:    "new_task",
-			Message: fmt.Sprintf("You are following a new task: %s", task.Label),
+			Message: fmt.Sprintf("You are following a new task: %s", task.NotificationLabel()),
 		}
-		if err := database.DB.Create(&notification).Error; err != nil {
+		if err := db.Create(&notification).Error; err != nil {
 			// Handle error
 		}
 	}
@@ -245,14 +355,13 @@ func CreateTask(c *gin.Context) {
 			TaskID:  task.ID,
 			GroupID: groupID,
 		}
-		if err := database.DB.Create(&followUpGroup).Error; err != nil {
+		if err := db.Create(&followUpGroup).Error; err != nil {
 			// Handle error, perhaps rollback task creation or log it
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign task to follow-up group"})
-			return
+			return task, "Failed to assign task to follow-up group"
 		}
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": task})
+	return task, ""
 }
 
 // GetTasks retrieves all tasks created by the authenticated user
@@ -265,6 +374,8 @@ func GetTasks(c *gin.Context) {
 		Preload("AssignedGroups.Group.Users").
 		Preload("FollowupUsers.User").
 		Preload("FollowupGroups.Group.Users").
+		Preload("Tags").
+		Preload("FieldValues.Field").
 		Where("created_by = ?", authUserID).
 		Order("created_at DESC").
 		Find(&tasks).Error; err != nil {
@@ -272,27 +383,64 @@ func GetTasks(c *gin.Context) {
 		return
 	}
 
+	renderTasksMarkdown(database.DB, authUserID, tasks)
+
 	c.JSON(http.StatusOK, gin.H{"data": tasks})
 }
 
 // GetTaskByID retrieves a single task by ID
 func GetTaskByID(c *gin.Context) {
 	id := c.Param("id")
-	var task models.Task
 
-	if err := database.DB.Preload("AssignedUsers.User").Preload("AssignedGroups.Group.Users").Preload("FollowupUsers.User").Preload("FollowupGroups.Group.Users").Preload("Comments.User").Preload("Creator").Where("id = ?", id).First(&task).Error; err != nil {
+	if _, ok := findVisibleTask(c, id); !ok {
+		return
+	}
+
+	commentPage, ok := parsePaginationParams(c, "comments_page", "comments_page_size", defaultCommentPageSize)
+	if !ok {
+		return
+	}
+
+	task, err := loadTaskRepresentation(database.DB, id)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found"}})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": task})
+	// Record the view for the creator, assignees and follow-ups
+	authUserID := uint(c.MustGet("user_id").(float64))
+	allowed, err := isUserAssignedOrFollowup(database.DB, authUserID, task.ID)
+	if err == nil && (allowed || task.CreatedBy == authUserID) {
+		if err := markTaskSeen(database.DB, task.ID, authUserID); err != nil {
+			// Handle error
+		}
+	}
+
+	// The ETag is the version to send back in If-Match. It is not used for 304 responses:
+	// the body also holds comments, which do not change the version, and varies by reader
+	// and comment page.
+	c.Header("ETag", taskETag(task))
+
+	renderTaskMarkdown(database.DB, authUserID, &task)
+
+	// Comments come a page of threads at a time, the rest through GetTaskComments
+	comments, err := loadCommentThreads(database.DB, task.ID, &commentPage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+	presentComments(database.DB, authUserID, comments)
+	task.Comments = comments
+
+	c.JSON(http.StatusOK, gin.H{"data": task, "comments_pagination": commentPage})
 }
 
-// isUserAssigned checks if a user is assigned to a task, either directly or through a group
+// isUserAssigned checks if a user is assigned to a task, either directly or through a group.
+// A declined assignment does not count.
 func isUserAssigned(db *gorm.DB, userID uint, taskID uint) (bool, error) {
 	// Check for direct assignment
 	var directAssignment int64
-	err := db.Model(&models.AssignTaskToUser{}).Where("task_id = ? AND user_id = ?", taskID, userID).Count(&directAssignment).Error
+	err := db.Model(&models.AssignTaskToUser{}).Where("task_id = ? AND user_id = ? AND status <> ?", taskID, userID, models.AssignmentDeclined).Count(&directAssignment).Error
 	if err != nil {
 		return false, err
 	}
@@ -302,9 +450,11 @@ func isUserAssigned(db *gorm.DB, userID uint, taskID uint) (bool, error) {
 
 	// Check for group assignment
 	var groupAssignment int64
+	// Groups do not count on restricted and private tasks
 	err = db.Model(&models.UserGroup{}).
-		Joins("JOIN assign_task_to_groups ON user_groups.group_id = assign_task_to_groups.group_id").
-		Where("assign_task_to_groups.task_id = ? AND user_groups.user_id = ?", taskID, userID).
+		Joins("JOIN assign_task_to_groups ON user_groups.group_id = assign_task_to_groups.group_id AND assign_task_to_groups.deleted_at IS NULL").
+		Joins("JOIN tasks ON tasks.id = assign_task_to_groups.task_id").
+		Where("assign_task_to_groups.task_id = ? AND user_groups.user_id = ? AND tasks.confidentiality = ?", taskID, userID, models.ConfidentialityNormal).
 		Count(&groupAssignment).Error
 	if err != nil {
 		return false, err
@@ -313,6 +463,236 @@ func isUserAssigned(db *gorm.DB, userID uint, taskID uint) (bool, error) {
 	return groupAssignment > 0, nil
 }
 
+// isSuperAdmin checks if a user has the Super Admin label
+func isSuperAdmin(db *gorm.DB, userID uint) (bool, error) {
+	var user models.User
+	if err := db.Select("id", "user_label").First(&user, userID).Error; err != nil {
+		return false, err
+	}
+	return user.UserLabel == 1, nil
+}
+
+// createsSubtaskCycle checks whether making parentID the parent of taskID would make
+// the task its own ancestor. It fails if the parent task does not exist.
+func createsSubtaskCycle(db *gorm.DB, taskID uint, parentID uint) (bool, error) {
+	visited := make(map[uint]bool)
+	current := parentID
+	for {
+		if current == taskID {
+			return true, nil
+		}
+		if visited[current] {
+			return true, nil
+		}
+		visited[current] = true
+
+		var parent models.Task
+		if err := db.Select("id", "parent_id").First(&parent, current).Error; err != nil {
+			return false, err
+		}
+		if parent.ParentID == nil {
+			return false, nil
+		}
+		current = *parent.ParentID
+	}
+}
+
+// taskAssociationUpdate holds the lists that replace the associations of a task.
+// A nil list leaves the association unchanged.
+type taskAssociationUpdate struct {
+	AssignedToUsers  []uint
+	AssignedToGroups []uint
+	FollowUpUsers    []uint
+	FollowUpGroups   []uint
+	TagIDs           []uint
+
+	tags []models.Tag
+}
+
+// validate checks that the referenced tags, users and groups exist and returns an error message
+func (u *taskAssociationUpdate) validate(db *gorm.DB) string {
+	if u.TagIDs != nil {
+		tags, err := findTags(db, u.TagIDs)
+		if err != nil {
+			return err.Error()
+		}
+		u.tags = tags
+	}
+
+	for _, userID := range u.AssignedToUsers {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return fmt.Sprintf("User with ID %d not found", userID)
+		}
+	}
+	for _, groupID := range u.AssignedToGroups {
+		var group models.Group
+		if err := db.First(&group, groupID).Error; err != nil {
+			return fmt.Sprintf("Group with ID %d not found", groupID)
+		}
+	}
+	for _, userID := range u.FollowUpUsers {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return fmt.Sprintf("Follow-up user with ID %d not found", userID)
+		}
+	}
+	for _, groupID := range u.FollowUpGroups {
+		var group models.Group
+		if err := db.First(&group, groupID).Error; err != nil {
+			return fmt.Sprintf("Follow-up group with ID %d not found", groupID)
+		}
+	}
+	return ""
+}
+
+// recordChanges adds the differences to the stored associations to the change log
+func (u *taskAssociationUpdate) recordChanges(db *gorm.DB, taskID uint, changes *taskChangeLog) {
+	if u.TagIDs != nil {
+		changes.addIDSet("tags", taskTagIDs(db, taskID), u.TagIDs)
+	}
+	if u.AssignedToUsers != nil {
+		changes.addIDSet("assigned_users", taskIDSet(db, &models.AssignTaskToUser{}, "user_id", taskID), u.AssignedToUsers)
+	}
+	if u.AssignedToGroups != nil {
+		changes.addIDSet("assigned_groups", taskIDSet(db, &models.AssignTaskToGroup{}, "group_id", taskID), u.AssignedToGroups)
+	}
+	if u.FollowUpUsers != nil {
+		changes.addIDSet("follow_up_users", taskIDSet(db, &models.TaskFollowupUser{}, "user_id", taskID), u.FollowUpUsers)
+	}
+	if u.FollowUpGroups != nil {
+		changes.addIDSet("follow_up_groups", taskIDSet(db, &models.TaskFollowupGroup{}, "group_id", taskID), u.FollowUpGroups)
+	}
+}
+
+// apply replaces the associations inside a transaction. It returns the message to report on failure.
+func (u *taskAssociationUpdate) apply(tx *gorm.DB, task *models.Task) string {
+	if u.TagIDs != nil {
+		if err := tx.Model(task).Association("Tags").Replace(u.tags); err != nil {
+			return "Failed to update tags"
+		}
+	}
+
+	if u.AssignedToUsers != nil {
+		// Assignments that are kept keep their acceptance state
+		remove := tx.Unscoped().Where("task_id = ?", task.ID)
+		if len(u.AssignedToUsers) > 0 {
+			remove = remove.Where("user_id NOT IN ?", u.AssignedToUsers)
+		}
+		if err := remove.Delete(&models.AssignTaskToUser{}).Error; err != nil {
+			return "Failed to update assigned users"
+		}
+		existing := make(map[uint]bool)
+		for _, userID := range taskIDSet(tx, &models.AssignTaskToUser{}, "user_id", task.ID) {
+			existing[userID] = true
+		}
+		for _, userID := range uniqueIDs(u.AssignedToUsers) {
+			if existing[userID] {
+				continue
+			}
+			assignToUser := models.AssignTaskToUser{TaskID: task.ID, UserID: userID}
+			if err := tx.Create(&assignToUser).Error; err != nil {
+				return "Failed to assign task to user"
+			}
+		}
+	}
+
+	if u.AssignedToGroups != nil {
+		if err := tx.Unscoped().Where("task_id = ?", task.ID).Delete(&models.AssignTaskToGroup{}).Error; err != nil {
+			return "Failed to update assigned groups"
+		}
+		for _, groupID := range u.AssignedToGroups {
+			assignToGroup := models.AssignTaskToGroup{TaskID: task.ID, GroupID: groupID}
+			if err := tx.Create(&assignToGroup).Error; err != nil {
+				return "Failed to assign task to group"
+			}
+		}
+	}
+
+	if u.FollowUpUsers != nil {
+		if err := tx.Unscoped().Where("task_id = ?", task.ID).Delete(&models.TaskFollowupUser{}).Error; err != nil {
+			return "Failed to update follow-up users"
+		}
+		for _, userID := range u.FollowUpUsers {
+			followUpUser := models.TaskFollowupUser{TaskID: task.ID, UserID: userID}
+			if err := tx.Create(&followUpUser).Error; err != nil {
+				return "Failed to assign task to follow-up user"
+			}
+		}
+	}
+
+	if u.FollowUpGroups != nil {
+		if err := tx.Unscoped().Where("task_id = ?", task.ID).Delete(&models.TaskFollowupGroup{}).Error; err != nil {
+			return "Failed to update follow-up groups"
+		}
+		for _, groupID := range u.FollowUpGroups {
+			followUpGroup := models.TaskFollowupGroup{TaskID: task.ID, GroupID: groupID}
+			if err := tx.Create(&followUpGroup).Error; err != nil {
+				return "Failed to assign task to follow-up group"
+			}
+		}
+	}
+
+	return ""
+}
+
+// taskCustomFieldUpdate holds validated custom field values that replace those of a task
+type taskCustomFieldUpdate struct {
+	active           bool
+	taskTypeChanged  bool
+	values           []models.TaskFieldValue
+	replacedFieldIDs []uint
+}
+
+// prepareCustomFieldUpdate validates raw custom field values against the task type the task
+// has after the update (taskTypeID, 0 for unchanged). Changing the type replaces all values.
+func prepareCustomFieldUpdate(db *gorm.DB, task models.Task, taskTypeID uint, raw map[string]json.RawMessage) (taskCustomFieldUpdate, []string) {
+	update := taskCustomFieldUpdate{taskTypeChanged: taskTypeID != 0 && taskTypeID != task.TaskTypeID}
+	if raw == nil && !update.taskTypeChanged {
+		return update, nil
+	}
+	update.active = true
+
+	existing := make(map[uint]bool)
+	if !update.taskTypeChanged {
+		taskTypeID = task.TaskTypeID
+		var existingFieldIDs []uint
+		db.Model(&models.TaskFieldValue{}).Where("task_id = ?", task.ID).Pluck("field_id", &existingFieldIDs)
+		for _, fieldID := range existingFieldIDs {
+			existing[fieldID] = true
+		}
+	}
+
+	var errors []string
+	update.values, update.replacedFieldIDs, errors = validateCustomFields(db, taskTypeID, raw, existing)
+	return update, errors
+}
+
+// apply replaces the custom field values inside a transaction
+func (u taskCustomFieldUpdate) apply(tx *gorm.DB, taskID uint) error {
+	if !u.active {
+		return nil
+	}
+
+	clear := tx.Unscoped().Where("task_id = ?", taskID)
+	if !u.taskTypeChanged {
+		clear = clear.Where("field_id IN ?", u.replacedFieldIDs)
+	}
+	if u.taskTypeChanged || len(u.replacedFieldIDs) > 0 {
+		if err := clear.Delete(&models.TaskFieldValue{}).Error; err != nil {
+			return err
+		}
+	}
+
+	for i := range u.values {
+		u.values[i].TaskID = taskID
+	}
+	if len(u.values) > 0 {
+		return tx.Create(&u.values).Error
+	}
+	return nil
+}
+
 // UpdateTask updates an existing task
 func UpdateTask(c *gin.Context) {
 	id := c.Param("id")
@@ -350,6 +730,16 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
+	// Optimistic concurrency check
+	expectedVersion, ok := expectedTaskVersion(c, task, input.Version)
+	if !ok {
+		return
+	}
+	if expectedVersion != task.Version {
+		respondTaskVersionConflict(c, task.ID)
+		return
+	}
+
 	// Validate TaskTypeID, if provided
 	if input.TaskTypeID != 0 {
 		var taskType models.TaskType
@@ -359,63 +749,87 @@ func UpdateTask(c *gin.Context) {
 		}
 	}
 
-	// Validate AssignedToUsers, if provided
-	if input.AssignedToUsers != nil {
-		for _, userID := range input.AssignedToUsers {
-			var user models.User
-			if err := database.DB.First(&user, userID).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("User with ID %d not found", userID)}})
-				return
-			}
-		}
+	if !validAttachment(input.Attachment) {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Attachment must be a file uploaded with /upload-attachment"}})
+		return
 	}
 
-	// Validate AssignedToGroups, if provided
-	if input.AssignedToGroups != nil {
-		for _, groupID := range input.AssignedToGroups {
-			var group models.Group
-			if err := database.DB.First(&group, groupID).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Group with ID %d not found", groupID)}})
-				return
-			}
+	// Validate ParentID, if provided
+	if input.ParentID != nil {
+		cycle, err := createsSubtaskCycle(database.DB, task.ID, *input.ParentID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Parent task not found"}})
+			return
+		}
+		if cycle {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"A task cannot be a subtask of itself or of its own subtasks"}})
+			return
 		}
 	}
 
-	// Validate FollowUpUsers, if provided
-	if input.FollowUpUsers != nil {
-		for _, userID := range input.FollowUpUsers {
-			var user models.User
-			if err := database.DB.First(&user, userID).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Follow-up user with ID %d not found", userID)}})
-				return
-			}
-		}
+	// Validate CustomFields against the new task type, or the current one
+	customFields, fieldErrors := prepareCustomFieldUpdate(database.DB, task, input.TaskTypeID, input.CustomFields)
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": fieldErrors})
+		return
 	}
 
-	// Validate FollowUpGroups, if provided
-	if input.FollowUpGroups != nil {
-		for _, groupID := range input.FollowUpGroups {
-			var group models.Group
-			if err := database.DB.First(&group, groupID).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Follow-up group with ID %d not found", groupID)}})
-				return
-			}
-		}
+	// Validate tags, users and groups, if provided
+	associations := taskAssociationUpdate{
+		AssignedToUsers:  input.AssignedToUsers,
+		AssignedToGroups: input.AssignedToGroups,
+		FollowUpUsers:    input.FollowUpUsers,
+		FollowUpGroups:   input.FollowUpGroups,
+		TagIDs:           input.TagIDs,
+	}
+	if message := associations.validate(database.DB); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{message}})
+		return
 	}
 
+	// Capture the current state for the change history
+	before := task
+	changes := newTaskChangeLog(task.ID, authUserID)
+	var beforeCustomFields map[string]string
+	if customFields.active {
+		beforeCustomFields = taskCustomFieldValues(database.DB, task.ID)
+	}
+	associations.recordChanges(database.DB, task.ID, changes)
+
 	// Using a transaction to ensure atomicity
 	tx := database.DB.Begin()
 
+	// Claim the version so a concurrent update cannot overwrite this one
+	if bumped, err := bumpTaskVersion(tx, task.ID, expectedVersion); err != nil || !bumped {
+		tx.Rollback()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+			return
+		}
+		respondTaskVersionConflict(c, task.ID)
+		return
+	}
+
+	var dueDate *time.Time
+	if input.DueDate != nil {
+		dueDate = &input.DueDate.Time
+	}
+
 	// Update task fields
 	if err := tx.Model(&task).Updates(models.Task{
 		Label:       input.Label,
 		TaskTypeID:  input.TaskTypeID,
 		Priority:    input.Priority,
 		StartDate:   input.StartDate.Time,
-		DueDate:     &input.DueDate.Time,
+		DueDate:     dueDate,
 		Description: input.Description,
 		Attachment:  input.Attachment,
 		Status:      input.Status,
+
+		Confidentiality:   input.Confidentiality,
+		OriginalEstimate:  input.OriginalEstimate,
+		RemainingEstimate: input.RemainingEstimate,
+		ParentID:          input.ParentID,
 	}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
@@ -423,68 +837,47 @@ func UpdateTask(c *gin.Context) {
 	}
 
 	// Update associations
-	if input.AssignedToUsers != nil {
-		if err := tx.Where("task_id = ?", task.ID).Delete(&models.AssignTaskToUser{}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update assigned users"})
-			return
-		}
-		for _, userID := range input.AssignedToUsers {
-			assignToUser := models.AssignTaskToUser{TaskID: task.ID, UserID: userID}
-			if err := tx.Create(&assignToUser).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign task to user"})
-				return
-			}
-		}
+	if err := customFields.apply(tx, task.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update custom fields"})
+		return
 	}
 
-	if input.AssignedToGroups != nil {
-		if err := tx.Where("task_id = ?", task.ID).Delete(&models.AssignTaskToGroup{}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update assigned groups"})
-			return
-		}
-		for _, groupID := range input.AssignedToGroups {
-			assignToGroup := models.AssignTaskToGroup{TaskID: task.ID, GroupID: groupID}
-			if err := tx.Create(&assignToGroup).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign task to group"})
-				return
-			}
-		}
+	if message := associations.apply(tx, &task); message != "" {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+		return
 	}
 
-	if input.FollowUpUsers != nil {
-		if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskFollowupUser{}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update follow-up users"})
-			return
-		}
-		for _, userID := range input.FollowUpUsers {
-			followUpUser := models.TaskFollowupUser{TaskID: task.ID, UserID: userID}
-			if err := tx.Create(&followUpUser).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign task to follow-up user"})
-				return
-			}
-		}
+	// Record the change history
+	var after models.Task
+	if err := tx.First(&after, task.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record task history"})
+		return
+	}
+	changes.addTaskFields(before, after)
+	if beforeCustomFields != nil {
+		changes.addCustomFields(beforeCustomFields, taskCustomFieldValues(tx, task.ID))
+	}
+	if err := changes.save(tx); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record task history"})
+		return
 	}
 
-	if input.FollowUpGroups != nil {
-		if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskFollowupGroup{}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update follow-up groups"})
-			return
-		}
-		for _, groupID := range input.FollowUpGroups {
-			followUpGroup := models.TaskFollowupGroup{TaskID: task.ID, GroupID: groupID}
-			if err := tx.Create(&followUpGroup).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign task to follow-up group"})
-				return
-			}
-		}
+	if err := touchTaskActivity(tx, task.ID, authUserID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task activity"})
+		return
+	}
+
+	// Only mentions added by this update are notified
+	mentions, err := processMentions(tx, after, authUserID, after.Description, before.Description, "the description of", input.AddMentionedAsFollowups)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to notify mentioned users"})
+		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
@@ -492,7 +885,15 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": task})
+	task.Version = after.Version
+	if len(mentions.AddedAsFollowups) > 0 {
+		database.DB.Preload("User").Where("task_id = ?", task.ID).Find(&task.FollowupUsers)
+		database.DB.Model(&models.Task{}).Where("id = ?", task.ID).Pluck("version", &task.Version)
+	}
+
+	renderTaskMarkdown(database.DB, authUserID, &task)
+	c.Header("ETag", taskETag(task))
+	c.JSON(http.StatusOK, gin.H{"data": task, "mentions": mentions})
 }
 
 // UpdateTaskStatus updates the status of a task by an assigned user
@@ -535,25 +936,30 @@ func UpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
+	// Optimistic concurrency check
+	expectedVersion, ok := expectedTaskVersion(c, task, input.Version)
+	if !ok {
+		return
+	}
+
 	// Using a transaction to ensure atomicity
 	tx := database.DB.Begin()
 
-	// Update task status
-	if err := tx.Model(&task).Update("status", input.Status).Error; err != nil {
+	// Claim the version so a concurrent update cannot overwrite this one
+	if bumped, err := bumpTaskVersion(tx, task.ID, expectedVersion); err != nil || !bumped {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
+			return
+		}
+		respondTaskVersionConflict(c, task.ID)
 		return
 	}
 
-	// Log the status update
-	statusLog := models.TaskStatusUpdateLog{
-		TaskID: task.ID,
-		UserID: authUserID,
-		Status: input.Status,
-	}
-	if err := tx.Create(&statusLog).Error; err != nil {
+	// Update task status and progress, and log the update
+	if err := recordTaskProgress(tx, task, authUserID, input.Status, input.Progress, input.Note); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log status update"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
 		return
 	}
 
@@ -563,9 +969,9 @@ func UpdateTaskStatus(c *gin.Context) {
 		database.DB.First(&user, authUserID)
 		notification := models.Notification{
 			UserID:  task.CreatedBy,
-			TaskID:  task.ID,
+			TaskID:  &task.ID,
 			Type:    "status_update",
-			Message: fmt.Sprintf("Task '%s' status updated to '%s' by %s", task.Label, input.Status, user.Username),
+			Message: fmt.Sprintf("Task '%s' status updated to '%s' by %s", task.NotificationLabel(), input.Status, user.Username),
 		}
 		if err := tx.Create(&notification).Error; err != nil {
 			// Handle error
@@ -577,7 +983,9 @@ func UpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Task status updated successfully"})
+	task.Version = expectedVersion + 1
+	c.Header("ETag", taskETag(task))
+	c.JSON(http.StatusOK, gin.H{"message": "Task status updated successfully", "version": task.Version})
 }
 
 // isUserAssignedOrFollowup checks if a user is assigned to a task (directly or via group) or is a followup user.
@@ -604,8 +1012,9 @@ func isUserAssignedOrFollowup(db *gorm.DB, userID uint, taskID uint) (bool, erro
 
 	var followupGroupAssignment int64
 	err = db.Model(&models.UserGroup{}).
-		Joins("JOIN task_followup_groups ON user_groups.group_id = task_followup_groups.group_id").
-		Where("task_followup_groups.task_id = ? AND user_groups.user_id = ?", taskID, userID).
+		Joins("JOIN task_followup_groups ON user_groups.group_id = task_followup_groups.group_id AND task_followup_groups.deleted_at IS NULL").
+		Joins("JOIN tasks ON tasks.id = task_followup_groups.task_id").
+		Where("task_followup_groups.task_id = ? AND user_groups.user_id = ? AND tasks.confidentiality = ?", taskID, userID, models.ConfidentialityNormal).
 		Count(&followupGroupAssignment).Error
 	if err != nil {
 		return false, err
@@ -617,27 +1026,19 @@ func isUserAssignedOrFollowup(db *gorm.DB, userID uint, taskID uint) (bool, erro
 
 // AddTaskComment adds a comment to a task
 func AddTaskComment(c *gin.Context) {
-	id := c.Param("id")
-	var task models.Task
-	if err := database.DB.First(&task, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found"}})
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
 		return
 	}
 
 	authUserID := uint(c.MustGet("user_id").(float64))
 
 	// Authorization check
-	allowed, err := isUserAssignedOrFollowup(database.DB, authUserID, task.ID)
+	allowed, err := canCommentOnTask(database.DB, authUserID, task)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user assignment"})
 		return
 	}
-
-	// Allow task creator to comment
-	if task.CreatedBy == authUserID {
-		allowed = true
-	}
-
 	if !allowed {
 		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to comment on this task"}})
 		return
@@ -649,60 +1050,111 @@ func AddTaskComment(c *gin.Context) {
 		return
 	}
 
-	comment := models.TaskCommentLog{
-		TaskID:  task.ID,
-		UserID:  authUserID,
-		Comment: input.Comment,
+	parentID, message := resolveCommentParent(database.DB, task.ID, input.ParentID)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{message}})
+		return
 	}
 
-	if err := database.DB.Create(&comment).Error; err != nil {
+	comment, mentions, err := addTaskComment(database.DB, task, authUserID, input.Comment, parentID, input.AddMentionedAsFollowups)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add comment"})
 		return
 	}
 
+	renderCommentMarkdown(database.DB, authUserID, &comment)
+	annotateCommentReactions(database.DB, authUserID, &comment)
+
+	c.JSON(http.StatusCreated, gin.H{"data": comment, "mentions": mentions})
+}
+
+// canCommentOnTask checks that a user created the task, is assigned to it or follows it
+func canCommentOnTask(db *gorm.DB, userID uint, task models.Task) (bool, error) {
+	if task.CreatedBy == userID {
+		return true, nil
+	}
+	return isUserAssignedOrFollowup(db, userID, task.ID)
+}
+
+// addTaskComment stores a comment, marks the task as active and notifies the mentioned
+// users, the creator, the assignees, the follow-up users and, for a reply, the author of
+// the comment it answers
+func addTaskComment(db *gorm.DB, task models.Task, userID uint, text string, parentID *uint, addMentionedAsFollowups bool) (models.TaskCommentLog, MentionResult, error) {
+	comment := models.TaskCommentLog{
+		TaskID:   task.ID,
+		UserID:   userID,
+		Comment:  text,
+		ParentID: parentID,
+	}
+
+	if err := db.Create(&comment).Error; err != nil {
+		return comment, MentionResult{}, err
+	}
+
+	if err := touchTaskActivity(db, task.ID, userID); err != nil {
+		// Handle error
+	}
+
+	mentions, err := processMentions(db, task, userID, text, "", "a comment on", addMentionedAsFollowups)
+	if err != nil {
+		return comment, mentions, err
+	}
+
 	// Create notifications for task creator and associated users
 	var usersToNotify []uint
 	usersToNotify = append(usersToNotify, task.CreatedBy)
 
 	var assignedUsers []models.AssignTaskToUser
-	database.DB.Where("task_id = ?", task.ID).Find(&assignedUsers)
+	db.Where("task_id = ? AND status <> ?", task.ID, models.AssignmentDeclined).Find(&assignedUsers)
 	for _, u := range assignedUsers {
 		usersToNotify = append(usersToNotify, u.UserID)
 	}
 
 	var followupUsers []models.TaskFollowupUser
-	database.DB.Where("task_id = ?", task.ID).Find(&followupUsers)
+	db.Where("task_id = ?", task.ID).Find(&followupUsers)
 	for _, u := range followupUsers {
 		usersToNotify = append(usersToNotify, u.UserID)
 	}
 
-	// Remove duplicates and the user who commented
+	if parentID != nil {
+		var parent models.TaskCommentLog
+		if err := db.First(&parent, *parentID).Error; err == nil {
+			usersToNotify = append(usersToNotify, parent.UserID)
+		}
+	}
+
+	// Remove duplicates, the user who commented and the mentioned users, who already got a
+	// mention notification
+	mentioned := make(map[uint]bool)
+	for _, id := range mentions.Notified {
+		mentioned[id] = true
+	}
 	userMap := make(map[uint]bool)
-	for _, userID := range usersToNotify {
-		if userID != authUserID {
-			userMap[userID] = true
+	for _, id := range usersToNotify {
+		if id != userID && !mentioned[id] {
+			userMap[id] = true
 		}
 	}
 
 	var user models.User
-	database.DB.First(&user, authUserID)
+	db.First(&user, userID)
 
-	for userID := range userMap {
+	for id := range userMap {
 		notification := models.Notification{
-			UserID:  userID,
-			TaskID:  task.ID,
+			UserID:  id,
+			TaskID:  &task.ID,
 			Type:    "new_comment",
-			Message: fmt.Sprintf("New comment on task '%s' by %s", task.Label, user.Username),
+			Message: fmt.Sprintf("New comment on task '%s' by %s", task.NotificationLabel(), user.Username),
 		}
-		if err := database.DB.Create(&notification).Error; err != nil {
+		if err := db.Create(&notification).Error; err != nil {
 			// Handle error
 		}
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": comment})
+	return comment, mentions, nil
 }
 
-// DeleteTask deletes a task
+// DeleteTask moves a task to the trash. It can be restored until it is purged.
 func DeleteTask(c *gin.Context) {
 	id := c.Param("id")
 	var task models.Task
@@ -719,99 +1171,58 @@ func DeleteTask(c *gin.Context) {
 		return
 	}
 
-	// Using a transaction to ensure atomicity
-	tx := database.DB.Begin()
-
-	// Delete associations
-	if err := tx.Where("task_id = ?", task.ID).Delete(&models.AssignTaskToUser{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task associations"})
-		return
-	}
-	if err := tx.Where("task_id = ?", task.ID).Delete(&models.AssignTaskToGroup{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task associations"})
-		return
-	}
-	if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskFollowupUser{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task associations"})
-		return
-	}
-	if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskFollowupGroup{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task associations"})
-		return
-	}
-	if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskStatusUpdateLog{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task associations"})
-		return
-	}
-	if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskCommentLog{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task associations"})
-		return
-	}
-	if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskSeenByUser{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task associations"})
-		return
-	}
-
-	// Delete the task itself
-	if err := tx.Delete(&task).Error; err != nil {
-		tx.Rollback()
+	// Move the task and its child records to the trash
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return trashTask(tx, &task, authUserID)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
 		return
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
 }
 
-// GetMyTasks retrieves tasks assigned to or followed by the authenticated user
-func GetMyTasks(c *gin.Context) {
-	authUserID := uint(c.MustGet("user_id").(float64))
-
+// myTaskIDs returns the IDs of tasks assigned to or followed by a user, directly or through a group
+func myTaskIDs(db *gorm.DB, userID uint) ([]uint, error) {
 	// Get all group IDs for the current user
 	var groupIDs []uint
-	if err := database.DB.Model(&models.UserGroup{}).Where("user_id = ?", authUserID).Pluck("group_id", &groupIDs).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
-		return
+	if err := db.Model(&models.UserGroup{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs).Error; err != nil {
+		return nil, err
 	}
 
 	taskIDMap := make(map[uint]bool)
 
-	// 1. Tasks assigned directly to the user
+	// 1. Tasks assigned directly to the user, unless declined
 	var assignedUserTaskIDs []uint
-	database.DB.Model(&models.AssignTaskToUser{}).Where("user_id = ?", authUserID).Pluck("task_id", &assignedUserTaskIDs)
+	db.Model(&models.AssignTaskToUser{}).Where("user_id = ? AND status <> ?", userID, models.AssignmentDeclined).Pluck("task_id", &assignedUserTaskIDs)
 	for _, id := range assignedUserTaskIDs {
 		taskIDMap[id] = true
 	}
 
 	// 2. Tasks followed directly by the user
 	var followupUserTaskIDs []uint
-	database.DB.Model(&models.TaskFollowupUser{}).Where("user_id = ?", authUserID).Pluck("task_id", &followupUserTaskIDs)
+	db.Model(&models.TaskFollowupUser{}).Where("user_id = ?", userID).Pluck("task_id", &followupUserTaskIDs)
 	for _, id := range followupUserTaskIDs {
 		taskIDMap[id] = true
 	}
 
 	if len(groupIDs) > 0 {
-		// 3. Tasks assigned to the user's groups
+		// 3. Tasks assigned to the user's groups, unless they are restricted or private
 		var assignedGroupTaskIDs []uint
-		database.DB.Model(&models.AssignTaskToGroup{}).Where("group_id IN ?", groupIDs).Pluck("task_id", &assignedGroupTaskIDs)
+		db.Model(&models.AssignTaskToGroup{}).
+			Joins("JOIN tasks ON tasks.id = assign_task_to_groups.task_id").
+			Where("assign_task_to_groups.group_id IN ? AND tasks.confidentiality = ?", groupIDs, models.ConfidentialityNormal).
+			Pluck("assign_task_to_groups.task_id", &assignedGroupTaskIDs)
 		for _, id := range assignedGroupTaskIDs {
 			taskIDMap[id] = true
 		}
 
-		// 4. Tasks followed by the user's groups
+		// 4. Tasks followed by the user's groups, unless they are restricted or private
 		var followupGroupTaskIDs []uint
-		database.DB.Model(&models.TaskFollowupGroup{}).Where("group_id IN ?", groupIDs).Pluck("task_id", &followupGroupTaskIDs)
+		db.Model(&models.TaskFollowupGroup{}).
+			Joins("JOIN tasks ON tasks.id = task_followup_groups.task_id").
+			Where("task_followup_groups.group_id IN ? AND tasks.confidentiality = ?", groupIDs, models.ConfidentialityNormal).
+			Pluck("task_followup_groups.task_id", &followupGroupTaskIDs)
 		for _, id := range followupGroupTaskIDs {
 			taskIDMap[id] = true
 		}
@@ -821,6 +1232,53 @@ func GetMyTasks(c *gin.Context) {
 	for id := range taskIDMap {
 		relevantTaskIDs = append(relevantTaskIDs, id)
 	}
+	return relevantTaskIDs, nil
+}
+
+// myTasksQuery returns a task query restricted to the tasks the user created, is assigned to or follows
+func myTasksQuery(db *gorm.DB, userID uint) (*gorm.DB, error) {
+	relevantTaskIDs, err := myTaskIDs(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Model(&models.Task{})
+	if len(relevantTaskIDs) > 0 {
+		return query.Where("created_by = ? OR id IN ?", userID, relevantTaskIDs), nil
+	}
+	return query.Where("created_by = ?", userID), nil
+}
+
+// applyMyTasksFilter narrows a task query with the filters of GetMyTasksFilterInput
+func applyMyTasksFilter(db *gorm.DB, filterInput GetMyTasksFilterInput) *gorm.DB {
+	if filterInput.FromDate != nil && !filterInput.FromDate.IsZero() {
+		db = db.Where("start_date >= ?", filterInput.FromDate.Time)
+	}
+	if filterInput.ToDate != nil && !filterInput.ToDate.IsZero() {
+		// To include the entire end day, add 23 hours, 59 minutes, 59 seconds
+		endOfDay := filterInput.ToDate.Time.Add(24 * time.Hour).Add(-time.Second)
+		db = db.Where("due_date <= ?", endOfDay)
+	}
+	if filterInput.Status != "" {
+		db = db.Where("status = ?", filterInput.Status)
+	}
+	if filterInput.TaskTypeID != 0 {
+		db = db.Where("task_type_id = ?", filterInput.TaskTypeID)
+	}
+	db = applyTagFilter(db, filterInput.TagIDs, filterInput.TagMatch)
+	db = applyCustomFieldFilters(db, filterInput.CustomFields)
+	return db
+}
+
+// GetMyTasks retrieves tasks assigned to or followed by the authenticated user
+func GetMyTasks(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	relevantTaskIDs, err := myTaskIDs(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+		return
+	}
 
 	var tasks []models.Task
 	if len(relevantTaskIDs) > 0 {
@@ -830,6 +1288,8 @@ func GetMyTasks(c *gin.Context) {
 			Preload("FollowupUsers.User").
 			Preload("FollowupGroups.Group.Users").
 			Preload("Creator").
+			Preload("Tags").
+			Preload("FieldValues.Field").
 			Where("id IN ?", relevantTaskIDs).
 			Order("created_at DESC").
 			Find(&tasks).Error; err != nil {
@@ -840,6 +1300,9 @@ func GetMyTasks(c *gin.Context) {
 		tasks = []models.Task{}
 	}
 
+	annotateReadState(database.DB, authUserID, tasks)
+	renderTasksMarkdown(database.DB, authUserID, tasks)
+
 	c.JSON(http.StatusOK, gin.H{"data": tasks})
 }
 
@@ -853,50 +1316,12 @@ func GetMyTasksFiltered(c *gin.Context) {
 		return
 	}
 
-	// Get all group IDs for the current user
-	var groupIDs []uint
-	if err := database.DB.Model(&models.UserGroup{}).Where("user_id = ?", authUserID).Pluck("group_id", &groupIDs).Error; err != nil {
+	relevantTaskIDs, err := myTaskIDs(database.DB, authUserID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
 		return
 	}
 
-	taskIDMap := make(map[uint]bool)
-
-	// 1. Tasks assigned directly to the user
-	var assignedUserTaskIDs []uint
-	database.DB.Model(&models.AssignTaskToUser{}).Where("user_id = ?", authUserID).Pluck("task_id", &assignedUserTaskIDs)
-	for _, id := range assignedUserTaskIDs {
-		taskIDMap[id] = true
-	}
-
-	// 2. Tasks followed directly by the user
-	var followupUserTaskIDs []uint
-	database.DB.Model(&models.TaskFollowupUser{}).Where("user_id = ?", authUserID).Pluck("task_id", &followupUserTaskIDs)
-	for _, id := range followupUserTaskIDs {
-		taskIDMap[id] = true
-	}
-
-	if len(groupIDs) > 0 {
-		// 3. Tasks assigned to the user's groups
-		var assignedGroupTaskIDs []uint
-		database.DB.Model(&models.AssignTaskToGroup{}).Where("group_id IN ?", groupIDs).Pluck("task_id", &assignedGroupTaskIDs)
-		for _, id := range assignedGroupTaskIDs {
-			taskIDMap[id] = true
-		}
-
-		// 4. Tasks followed by the user's groups
-		var followupGroupTaskIDs []uint
-		database.DB.Model(&models.TaskFollowupGroup{}).Where("group_id IN ?", groupIDs).Pluck("task_id", &followupGroupTaskIDs)
-		for _, id := range followupGroupTaskIDs {
-			taskIDMap[id] = true
-		}
-	}
-
-	var relevantTaskIDs []uint
-	for id := range taskIDMap {
-		relevantTaskIDs = append(relevantTaskIDs, id)
-	}
-
 	var tasks []models.Task
 	if len(relevantTaskIDs) > 0 {
 		db := database.DB.
@@ -905,24 +1330,13 @@ func GetMyTasksFiltered(c *gin.Context) {
 			Preload("FollowupUsers.User").
 			Preload("FollowupGroups.Group.Users").
 			Preload("Creator").
+			Preload("Tags").
+			Preload("FieldValues.Field").
 			Where("id IN ?", relevantTaskIDs).
 			Order("created_at DESC")
 
 		// Apply filters from JSON body
-		if filterInput.FromDate != nil && !filterInput.FromDate.IsZero() {
-			db = db.Where("start_date >= ?", filterInput.FromDate.Time)
-		}
-		if filterInput.ToDate != nil && !filterInput.ToDate.IsZero() {
-			// To include the entire end day, add 23 hours, 59 minutes, 59 seconds
-			endOfDay := filterInput.ToDate.Time.Add(24 * time.Hour).Add(-time.Second)
-			db = db.Where("due_date <= ?", endOfDay)
-		}
-		if filterInput.Status != "" {
-			db = db.Where("status = ?", filterInput.Status)
-		}
-		if filterInput.TaskTypeID != 0 {
-			db = db.Where("task_type_id = ?", filterInput.TaskTypeID)
-		}
+		db = applyMyTasksFilter(db, filterInput)
 
 		if err := db.Find(&tasks).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
@@ -932,5 +1346,8 @@ func GetMyTasksFiltered(c *gin.Context) {
 		tasks = []models.Task{}
 	}
 
+	annotateReadState(database.DB, authUserID, tasks)
+	renderTasksMarkdown(database.DB, authUserID, tasks)
+
 	c.JSON(http.StatusOK, gin.H{"data": tasks})
 }
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type AddTaskDependencyInput struct {
+	DependsOnID uint `json:"depends_on_id" binding:"required,gt=0"`
+}
+
+// createsDependencyCycle checks whether making taskID depend on dependsOnID would close a
+// loop, i.e. whether taskID already is a direct or indirect prerequisite of dependsOnID
+func createsDependencyCycle(db *gorm.DB, taskID uint, dependsOnID uint) (bool, error) {
+	visited := map[uint]bool{dependsOnID: true}
+	frontier := []uint{dependsOnID}
+	for len(frontier) > 0 {
+		if visited[taskID] {
+			return true, nil
+		}
+		var prerequisites []uint
+		if err := db.Model(&models.TaskDependency{}).Where("task_id IN ?", frontier).Pluck("depends_on_id", &prerequisites).Error; err != nil {
+			return false, err
+		}
+		frontier = nil
+		for _, id := range prerequisites {
+			if !visited[id] {
+				visited[id] = true
+				frontier = append(frontier, id)
+			}
+		}
+	}
+	return visited[taskID], nil
+}
+
+// taskDependencyIDs returns the IDs of the tasks a task depends on
+func taskDependencyIDs(db *gorm.DB, taskID uint) []uint {
+	return taskIDSet(db, &models.TaskDependency{}, "depends_on_id", taskID)
+}
+
+// findPlannableTask loads a visible task whose dependencies the caller may change:
+// its creator and its assignees
+func findPlannableTask(c *gin.Context) (models.Task, bool) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return task, false
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if task.CreatedBy != authUserID {
+		assigned, err := isUserAssigned(database.DB, authUserID, task.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user assignment"})
+			return task, false
+		}
+		if !assigned {
+			c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to change the dependencies of this task"}})
+			return task, false
+		}
+	}
+	return task, true
+}
+
+// saveTaskDependencyChange logs a change of the dependency list and bumps the task version
+func saveTaskDependencyChange(tx *gorm.DB, taskID uint, userID uint, before []uint) error {
+	changes := newTaskChangeLog(taskID, userID)
+	changes.addIDSet("depends_on", before, taskDependencyIDs(tx, taskID))
+	if err := changes.save(tx); err != nil {
+		return err
+	}
+	return incrementTaskVersion(tx, taskID)
+}
+
+// GetTaskDependencies lists the prerequisites of a task and the tasks that depend on it
+func GetTaskDependencies(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var prerequisites, dependents []models.TaskDependency
+	if err := database.DB.Where("task_id = ?", task.ID).Find(&prerequisites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dependencies"})
+		return
+	}
+	if err := database.DB.Where("depends_on_id = ?", task.ID).Find(&dependents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dependencies"})
+		return
+	}
+
+	// Only tasks the caller can see are listed
+	visibleTasks := func(ids []uint) []models.Task {
+		result := []models.Task{}
+		if len(ids) == 0 {
+			return result
+		}
+		var tasks []models.Task
+		database.DB.Where("id IN ?", ids).Order("start_date, id").Find(&tasks)
+		for _, t := range tasks {
+			if visible, err := canViewTask(database.DB, authUserID, t); err == nil && visible {
+				result = append(result, t)
+			}
+		}
+		return result
+	}
+	var prerequisiteIDs, dependentIDs []uint
+	for _, d := range prerequisites {
+		prerequisiteIDs = append(prerequisiteIDs, d.DependsOnID)
+	}
+	for _, d := range dependents {
+		dependentIDs = append(dependentIDs, d.TaskID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"depends_on":  visibleTasks(prerequisiteIDs),
+		"required_by": visibleTasks(dependentIDs),
+	}})
+}
+
+// AddTaskDependency makes a task depend on another one
+func AddTaskDependency(c *gin.Context) {
+	task, ok := findPlannableTask(c)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var input AddTaskDependencyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			_ = ve.Translate(trans)
+			for _, e := range ve {
+				errors = append(errors, e.Translate(trans))
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	if input.DependsOnID == task.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"A task cannot depend on itself"}})
+		return
+	}
+
+	var prerequisite models.Task
+	if err := database.DB.First(&prerequisite, input.DependsOnID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Task with ID %d not found", input.DependsOnID)}})
+		return
+	}
+	visible, err := canViewTask(database.DB, authUserID, prerequisite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task visibility"})
+		return
+	}
+	if !visible {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("Task with ID %d not found", input.DependsOnID)}})
+		return
+	}
+
+	var existing int64
+	database.DB.Model(&models.TaskDependency{}).Where("task_id = ? AND depends_on_id = ?", task.ID, input.DependsOnID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"errors": []string{"The task already depends on this task"}})
+		return
+	}
+
+	cycle, err := createsDependencyCycle(database.DB, task.ID, input.DependsOnID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check dependencies"})
+		return
+	}
+	if cycle {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"The dependency would create a cycle"}})
+		return
+	}
+
+	before := taskDependencyIDs(database.DB, task.ID)
+	dependency := models.TaskDependency{TaskID: task.ID, DependsOnID: input.DependsOnID, CreatedBy: authUserID}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&dependency).Error; err != nil {
+			return err
+		}
+		return saveTaskDependencyChange(tx, task.ID, authUserID, before)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add dependency"})
+		return
+	}
+
+	dependency.DependsOn = &prerequisite
+	c.JSON(http.StatusCreated, gin.H{"data": dependency})
+}
+
+// RemoveTaskDependency removes a dependency of a task
+func RemoveTaskDependency(c *gin.Context) {
+	task, ok := findPlannableTask(c)
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	dependsOnID, err := strconv.ParseUint(c.Param("dependsOnId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid task ID"}})
+		return
+	}
+
+	var dependency models.TaskDependency
+	if err := database.DB.Where("task_id = ? AND depends_on_id = ?", task.ID, dependsOnID).First(&dependency).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Dependency not found"}})
+		return
+	}
+
+	before := taskDependencyIDs(database.DB, task.ID)
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&dependency).Error; err != nil {
+			return err
+		}
+		return saveTaskDependencyChange(tx, task.ID, authUserID, before)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove dependency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dependency removed successfully"})
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// taskChangeLog collects field changes of one task mutation before they are written
+type taskChangeLog struct {
+	taskID  uint
+	userID  *uint
+	changes []models.TaskHistory
+}
+
+func newTaskChangeLog(taskID uint, userID uint) *taskChangeLog {
+	return &taskChangeLog{taskID: taskID, userID: &userID}
+}
+
+// add records a change if the value actually changed
+func (l *taskChangeLog) add(field string, oldValue string, newValue string) {
+	if oldValue == newValue {
+		return
+	}
+	l.changes = append(l.changes, models.TaskHistory{
+		TaskID:   l.taskID,
+		UserID:   l.userID,
+		Field:    field,
+		OldValue: oldValue,
+		NewValue: newValue,
+	})
+}
+
+// addIDSet records a change of an ID list such as the assigned users
+func (l *taskChangeLog) addIDSet(field string, before []uint, after []uint) {
+	l.add(field, formatIDSet(before), formatIDSet(after))
+}
+
+// addTaskFields compares the scalar fields of a task before and after a mutation
+func (l *taskChangeLog) addTaskFields(before models.Task, after models.Task) {
+	l.add("label", before.Label, after.Label)
+	l.add("task_type_id", formatID(before.TaskTypeID), formatID(after.TaskTypeID))
+	l.add("priority", before.Priority, after.Priority)
+	l.add("start_date", formatDate(&before.StartDate), formatDate(&after.StartDate))
+	l.add("due_date", formatDate(before.DueDate), formatDate(after.DueDate))
+	l.add("description", before.Description, after.Description)
+	l.add("attachment", before.Attachment, after.Attachment)
+	l.add("status", before.Status, after.Status)
+	l.add("confidentiality", before.Confidentiality, after.Confidentiality)
+	l.add("original_estimate", formatOptionalUint(before.OriginalEstimate), formatOptionalUint(after.OriginalEstimate))
+	l.add("remaining_estimate", formatOptionalUint(before.RemainingEstimate), formatOptionalUint(after.RemainingEstimate))
+	l.add("parent_id", formatOptionalUint(before.ParentID), formatOptionalUint(after.ParentID))
+}
+
+// save writes the collected changes
+func (l *taskChangeLog) save(tx *gorm.DB) error {
+	if len(l.changes) == 0 {
+		return nil
+	}
+	return tx.Create(&l.changes).Error
+}
+
+// taskIDSet plucks a column of a task child table, e.g. the user IDs of its assignments
+func taskIDSet(db *gorm.DB, model interface{}, column string, taskID uint) []uint {
+	var ids []uint
+	db.Model(model).Where("task_id = ?", taskID).Pluck(column, &ids)
+	return ids
+}
+
+// taskTagIDs returns the IDs of the tags on a task
+func taskTagIDs(db *gorm.DB, taskID uint) []uint {
+	var ids []uint
+	db.Table("task_tags").Where("task_id = ?", taskID).Pluck("tag_id", &ids)
+	return ids
+}
+
+// taskCustomFieldValues returns the custom field values of a task keyed by field key
+func taskCustomFieldValues(db *gorm.DB, taskID uint) map[string]string {
+	var values []models.TaskFieldValue
+	db.Preload("Field").Where("task_id = ?", taskID).Order("id").Find(&values)
+	return customFieldValueMap(values)
+}
+
+func customFieldValueMap(values []models.TaskFieldValue) map[string]string {
+	grouped := make(map[string][]string)
+	for _, value := range values {
+		grouped[value.Field.Key] = append(grouped[value.Field.Key], value.Value)
+	}
+	result := make(map[string]string)
+	for key, list := range grouped {
+		result[key] = strings.Join(list, ", ")
+	}
+	return result
+}
+
+// addCustomFields records changed custom field values as "custom_field:<key>"
+func (l *taskChangeLog) addCustomFields(before map[string]string, after map[string]string) {
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		l.add("custom_field:"+key, before[key], after[key])
+	}
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func formatOptionalUint(v *uint) string {
+	if v == nil {
+		return ""
+	}
+	return formatID(*v)
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func formatIDSet(ids []uint) string {
+	unique := uniqueIDs(ids)
+	sort.Slice(unique, func(i, j int) bool { return unique[i] < unique[j] })
+	parts := make([]string, len(unique))
+	for i, id := range unique {
+		parts[i] = formatID(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// GetTaskHistory lists the field changes of a task, newest first
+func GetTaskHistory(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	db := database.DB.Preload("User").Where("task_id = ?", task.ID).Order("created_at DESC, id DESC")
+	if field := c.Query("field"); field != "" {
+		db = db.Where("field = ?", field)
+	}
+
+	var history []models.TaskHistory
+	if err := db.Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve task history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": history})
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"gorm.io/gorm"
+)
+
+// Longest description and comment accepted, in characters, since they are rendered again on
+// every read. The binding tags of the task and comment inputs use the same limits.
+const (
+	maxDescriptionLength = 50000
+	maxCommentLength     = 10000
+)
+
+// taskRefResolver resolves the task references (#123) of texts for a reader. Only tasks
+// the reader can see are linked, so references do not reveal other tasks' labels.
+func taskRefResolver(db *gorm.DB, userID uint, texts ...string) utils.TaskRefFunc {
+	var ids []uint
+	for _, text := range texts {
+		ids = append(ids, utils.TaskRefIDs(text)...)
+	}
+
+	titles := make(map[uint]string)
+	if len(ids) > 0 {
+		var tasks []models.Task
+		db.Where("id IN ?", uniqueIDs(ids)).Find(&tasks)
+		for _, task := range tasks {
+			if visible, err := canViewTask(db, userID, task); err == nil && visible {
+				titles[task.ID] = task.Label
+			}
+		}
+	}
+
+	return func(id uint) (string, bool) {
+		title, ok := titles[id]
+		return title, ok
+	}
+}
+
+// renderTaskMarkdown sets the HTML of the descriptions of tasks, and of their comments when
+// loaded, as seen by the given user
+func renderTaskMarkdown(db *gorm.DB, userID uint, tasks ...*models.Task) {
+	var texts []string
+	for _, task := range tasks {
+		texts = append(texts, task.Description)
+		for _, comment := range task.Comments {
+			texts = append(texts, comment.Comment)
+		}
+	}
+	taskRef := taskRefResolver(db, userID, texts...)
+
+	for _, task := range tasks {
+		task.DescriptionHTML = utils.RenderMarkdown(task.Description, taskRef)
+		for i := range task.Comments {
+			task.Comments[i].CommentHTML = utils.RenderMarkdown(task.Comments[i].Comment, taskRef)
+		}
+	}
+}
+
+// renderTasksMarkdown is renderTaskMarkdown for a list of tasks
+func renderTasksMarkdown(db *gorm.DB, userID uint, tasks []models.Task) {
+	pointers := make([]*models.Task, len(tasks))
+	for i := range tasks {
+		pointers[i] = &tasks[i]
+	}
+	renderTaskMarkdown(db, userID, pointers...)
+}
+
+// renderCommentMarkdown sets the HTML of comments as seen by the given user
+func renderCommentMarkdown(db *gorm.DB, userID uint, comments ...*models.TaskCommentLog) {
+	var texts []string
+	for _, comment := range comments {
+		texts = append(texts, comment.Comment)
+	}
+	taskRef := taskRefResolver(db, userID, texts...)
+
+	for _, comment := range comments {
+		comment.CommentHTML = utils.RenderMarkdown(comment.Comment, taskRef)
+	}
+}
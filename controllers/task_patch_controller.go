@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"taskmanager/database"
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PatchTaskInput is a JSON merge patch (RFC 7396) of a task: absent members are left
+// unchanged and null clears a field, or is rejected for fields that cannot be empty
+type PatchTaskInput struct {
+	Label             utils.PatchString   `json:"label"`
+	TaskTypeID        utils.PatchUint     `json:"task_type_id"`
+	Priority          utils.PatchString   `json:"priority"`
+	StartDate         utils.NullableDate  `json:"start_date"`
+	DueDate           utils.NullableDate  `json:"due_date"`
+	Description       utils.PatchString   `json:"description"`
+	Attachment        utils.PatchString   `json:"attachment"`
+	Status            utils.PatchString   `json:"status"`
+	Confidentiality   utils.PatchString   `json:"confidentiality"`
+	AssignedToUsers   utils.PatchUintList `json:"assigned_to_users"`
+	AssignedToGroups  utils.PatchUintList `json:"assigned_to_groups"`
+	FollowUpUsers     utils.PatchUintList `json:"follow_up_users"`
+	FollowUpGroups    utils.PatchUintList `json:"follow_up_groups"`
+	OriginalEstimate  utils.PatchUint     `json:"original_estimate"`  // minutes
+	RemainingEstimate utils.PatchUint     `json:"remaining_estimate"` // minutes
+	ParentID          utils.PatchUint     `json:"parent_id"`
+	TagIDs            utils.PatchUintList `json:"tag_ids"`
+	CustomFields      json.RawMessage     `json:"custom_fields"` // merged per field key, null clears all
+	Version           *uint               `json:"version"`       // alternative to the If-Match header
+
+	AddMentionedAsFollowups bool `json:"add_mentioned_as_followups"` // let newly mentioned users who cannot see the task follow it
+}
+
+var taskPriorities = []string{"Normal", "Medium", "High", "Escalation"}
+var taskStatuses = []string{"Pending", "In Progress", "In Review", "Completed"}
+var taskConfidentialityLevels = []string{models.ConfidentialityNormal, models.ConfidentialityRestricted, models.ConfidentialityPrivate}
+
+// patchIDList returns the list of a patch member, nil when it was not sent
+func patchIDList(p utils.PatchUintList) []uint {
+	if !p.Present {
+		return nil
+	}
+	return p.Values
+}
+
+// validateIDList checks that a patched ID list only holds valid IDs
+func validateIDList(p utils.PatchUintList, name string) []string {
+	for _, id := range p.Values {
+		if id == 0 {
+			return []string{fmt.Sprintf("%s must only contain IDs greater than 0", name)}
+		}
+	}
+	return nil
+}
+
+// taskPatchUpdates validates the scalar members of a patch and returns the columns to update
+func taskPatchUpdates(task models.Task, input PatchTaskInput) (map[string]interface{}, []string) {
+	updates := map[string]interface{}{}
+	var errors []string
+
+	if input.Label.Present {
+		length := utf8.RuneCountInString(input.Label.Value)
+		switch {
+		case input.Label.Null:
+			errors = append(errors, "Task label cannot be null")
+		case length < 3:
+			errors = append(errors, "Task label must be at least 3 characters long")
+		case length > 255:
+			errors = append(errors, "Task label cannot exceed 255 characters")
+		default:
+			updates["label"] = input.Label.Value
+		}
+	}
+
+	if input.TaskTypeID.Present {
+		var taskType models.TaskType
+		if input.TaskTypeID.Null {
+			errors = append(errors, "Task type cannot be null")
+		} else if err := database.DB.First(&taskType, input.TaskTypeID.Value).Error; err != nil {
+			errors = append(errors, "Invalid task type ID")
+		} else {
+			updates["task_type_id"] = input.TaskTypeID.Value
+		}
+	}
+
+	if input.Priority.Present {
+		if input.Priority.Null {
+			errors = append(errors, "Priority cannot be null")
+		} else if !containsString(taskPriorities, input.Priority.Value) {
+			errors = append(errors, "Invalid priority value. Must be Normal, Medium, High, or Escalation")
+		} else {
+			updates["priority"] = input.Priority.Value
+		}
+	}
+
+	if input.Status.Present {
+		if input.Status.Null {
+			errors = append(errors, "Status cannot be null")
+		} else if !containsString(taskStatuses, input.Status.Value) {
+			errors = append(errors, "Invalid status value. Must be Pending, In Progress, In Review, or Completed")
+		} else {
+			updates["status"] = input.Status.Value
+		}
+	}
+
+	if input.Confidentiality.Present {
+		if input.Confidentiality.Null {
+			errors = append(errors, "Confidentiality cannot be null")
+		} else if !containsString(taskConfidentialityLevels, input.Confidentiality.Value) {
+			errors = append(errors, "Invalid confidentiality value. Must be normal, restricted, or private")
+		} else {
+			updates["confidentiality"] = input.Confidentiality.Value
+		}
+	}
+
+	if input.Description.Present {
+		if utf8.RuneCountInString(input.Description.Value) > maxDescriptionLength {
+			errors = append(errors, fmt.Sprintf("Description must be at most %d characters", maxDescriptionLength))
+		} else {
+			updates["description"] = input.Description.Value
+		}
+	}
+	if input.Attachment.Present {
+		if !validAttachment(input.Attachment.Value) {
+			errors = append(errors, "Attachment must be a file uploaded with /upload-attachment")
+		} else {
+			updates["attachment"] = input.Attachment.Value
+		}
+	}
+
+	// Check the dates as they will be after the patch
+	startDate := task.StartDate
+	dueDate := task.DueDate
+	if input.StartDate.Present {
+		if input.StartDate.Null {
+			errors = append(errors, "Start date cannot be null")
+		} else {
+			startDate = input.StartDate.Time
+			updates["start_date"] = startDate
+		}
+	}
+	if input.DueDate.Present {
+		dueDate = input.DueDate.Ptr()
+		updates["due_date"] = dueDate
+	}
+	if (input.StartDate.Present || input.DueDate.Present) && dueDate != nil && dueDate.Before(startDate) {
+		errors = append(errors, "Due date must be greater than or equal to start date")
+	}
+
+	if input.OriginalEstimate.Present {
+		updates["original_estimate"] = input.OriginalEstimate.Ptr()
+	}
+	if input.RemainingEstimate.Present {
+		updates["remaining_estimate"] = input.RemainingEstimate.Ptr()
+	}
+
+	if input.ParentID.Present {
+		if input.ParentID.Null {
+			updates["parent_id"] = nil
+		} else if input.ParentID.Value == 0 {
+			errors = append(errors, "Parent task ID must be greater than 0")
+		} else if cycle, err := createsSubtaskCycle(database.DB, task.ID, input.ParentID.Value); err != nil {
+			errors = append(errors, "Parent task not found")
+		} else if cycle {
+			errors = append(errors, "A task cannot be a subtask of itself or of its own subtasks")
+		} else {
+			updates["parent_id"] = input.ParentID.Value
+		}
+	}
+
+	return updates, errors
+}
+
+// patchCustomFields turns the custom_fields member of a patch into the raw values to validate.
+// null clears every field of the task type the task has after the patch.
+func patchCustomFields(task models.Task, input PatchTaskInput) (map[string]json.RawMessage, []string) {
+	if input.CustomFields == nil {
+		return nil, nil
+	}
+
+	if string(input.CustomFields) == "null" {
+		taskTypeID := task.TaskTypeID
+		if input.TaskTypeID.Present && !input.TaskTypeID.Null {
+			taskTypeID = input.TaskTypeID.Value
+		}
+		var keys []string
+		database.DB.Model(&models.TaskTypeField{}).Where("task_type_id = ?", taskTypeID).Pluck("`key`", &keys)
+		raw := make(map[string]json.RawMessage)
+		for _, key := range keys {
+			raw[key] = json.RawMessage("null")
+		}
+		return raw, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(input.CustomFields, &raw); err != nil {
+		return nil, []string{"Custom fields must be an object keyed by field key"}
+	}
+	return raw, nil
+}
+
+// PatchTask partially updates a task with JSON merge patch semantics
+func PatchTask(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+
+	if err := database.DB.First(&task, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found"}})
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if task.CreatedBy != authUserID {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to update this task"}})
+		return
+	}
+
+	var input PatchTaskInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	// Optimistic concurrency check
+	expectedVersion, ok := expectedTaskVersion(c, task, input.Version)
+	if !ok {
+		return
+	}
+	if expectedVersion != task.Version {
+		respondTaskVersionConflict(c, task.ID)
+		return
+	}
+
+	// Validate every member and report all problems at once
+	updates, errors := taskPatchUpdates(task, input)
+	errors = append(errors, validateIDList(input.AssignedToUsers, "Assigned users")...)
+	errors = append(errors, validateIDList(input.AssignedToGroups, "Assigned groups")...)
+	errors = append(errors, validateIDList(input.FollowUpUsers, "Follow-up users")...)
+	errors = append(errors, validateIDList(input.FollowUpGroups, "Follow-up groups")...)
+	errors = append(errors, validateIDList(input.TagIDs, "Tags")...)
+	rawCustomFields, fieldErrors := patchCustomFields(task, input)
+	errors = append(errors, fieldErrors...)
+	if len(errors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	var taskTypeID uint
+	if input.TaskTypeID.Present {
+		taskTypeID = input.TaskTypeID.Value
+	}
+	customFields, fieldErrors := prepareCustomFieldUpdate(database.DB, task, taskTypeID, rawCustomFields)
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": fieldErrors})
+		return
+	}
+
+	associations := taskAssociationUpdate{
+		AssignedToUsers:  patchIDList(input.AssignedToUsers),
+		AssignedToGroups: patchIDList(input.AssignedToGroups),
+		FollowUpUsers:    patchIDList(input.FollowUpUsers),
+		FollowUpGroups:   patchIDList(input.FollowUpGroups),
+		TagIDs:           patchIDList(input.TagIDs),
+	}
+	if message := associations.validate(database.DB); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{message}})
+		return
+	}
+
+	// Capture the current state for the change history
+	before := task
+	changes := newTaskChangeLog(task.ID, authUserID)
+	var beforeCustomFields map[string]string
+	if customFields.active {
+		beforeCustomFields = taskCustomFieldValues(database.DB, task.ID)
+	}
+	associations.recordChanges(database.DB, task.ID, changes)
+
+	// Using a transaction to ensure atomicity
+	tx := database.DB.Begin()
+
+	// Claim the version so a concurrent update cannot overwrite this one
+	if bumped, err := bumpTaskVersion(tx, task.ID, expectedVersion); err != nil || !bumped {
+		tx.Rollback()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+			return
+		}
+		respondTaskVersionConflict(c, task.ID)
+		return
+	}
+
+	// A map is used so that null and empty values are written too
+	if len(updates) > 0 {
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+			return
+		}
+	}
+
+	if err := customFields.apply(tx, task.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update custom fields"})
+		return
+	}
+
+	if message := associations.apply(tx, &task); message != "" {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+		return
+	}
+
+	// Record the change history
+	var after models.Task
+	if err := tx.First(&after, task.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record task history"})
+		return
+	}
+	changes.addTaskFields(before, after)
+	if beforeCustomFields != nil {
+		changes.addCustomFields(beforeCustomFields, taskCustomFieldValues(tx, task.ID))
+	}
+	if err := changes.save(tx); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record task history"})
+		return
+	}
+
+	if err := touchTaskActivity(tx, task.ID, authUserID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task activity"})
+		return
+	}
+
+	// Only mentions added by this update are notified
+	mentions, err := processMentions(tx, after, authUserID, after.Description, before.Description, "the description of", input.AddMentionedAsFollowups)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to notify mentioned users"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	if len(mentions.AddedAsFollowups) > 0 {
+		database.DB.Model(&models.Task{}).Where("id = ?", task.ID).Pluck("version", &after.Version)
+	}
+
+	renderTaskMarkdown(database.DB, authUserID, &after)
+	c.Header("ETag", taskETag(after))
+	c.JSON(http.StatusOK, gin.H{"data": after, "mentions": mentions})
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type UpdateTaskProgressInput struct {
+	Progress *uint  `json:"progress" binding:"required,lte=100"` // percent
+	Note     string `json:"note" binding:"max=2000"`
+	Version  *uint  `json:"version"` // alternative to the If-Match header
+}
+
+// ProgressTimelineEntry is one status or progress update in the timeline of a task
+type ProgressTimelineEntry struct {
+	ID            uint      `json:"id"`
+	UserID        uint      `json:"user_id"`
+	Username      string    `json:"username"`
+	Status        string    `json:"status"`
+	StatusChanged bool      `json:"status_changed"` // false for progress-only updates
+	Progress      *uint     `json:"progress"`
+	Note          string    `json:"note,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// recordTaskProgress sets the status and progress of a task and logs the update with its note.
+// A nil progress keeps the current one, except that completing a task sets it to 100%.
+func recordTaskProgress(tx *gorm.DB, task models.Task, userID uint, status string, progress *uint, note string) error {
+	if progress == nil && status == "Completed" && task.Status != "Completed" {
+		full := uint(100)
+		progress = &full
+	}
+
+	changes := newTaskChangeLog(task.ID, userID)
+	updates := map[string]interface{}{}
+	if status != task.Status {
+		updates["status"] = status
+		changes.add("status", task.Status, status)
+	}
+	if progress != nil {
+		updates["progress"] = *progress
+		changes.add("progress", formatID(task.Progress), formatID(*progress))
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	if err := changes.save(tx); err != nil {
+		return err
+	}
+
+	statusLog := models.TaskStatusUpdateLog{
+		TaskID:   task.ID,
+		UserID:   userID,
+		Status:   status,
+		Progress: progress,
+		Note:     note,
+	}
+	if err := tx.Create(&statusLog).Error; err != nil {
+		return err
+	}
+
+	return touchTaskActivity(tx, task.ID, userID)
+}
+
+// UpdateTaskProgress lets an assigned user report progress without changing the status
+func UpdateTaskProgress(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+	if err := database.DB.First(&task, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found"}})
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	// Authorization check
+	assigned, err := isUserAssigned(database.DB, authUserID, task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user assignment"})
+		return
+	}
+	if !assigned {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to update the progress of this task"}})
+		return
+	}
+
+	var input UpdateTaskProgressInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errors []string
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			en := en.New()
+			uni := ut.New(en, en)
+			trans, _ := uni.GetTranslator("en")
+			_ = ve.Translate(trans)
+			for _, e := range ve {
+				switch e.Field() {
+				case "Progress":
+					if e.Tag() == "required" {
+						errors = append(errors, "Progress is required")
+					} else {
+						errors = append(errors, "Progress must be between 0 and 100")
+					}
+				case "Note":
+					errors = append(errors, "Note cannot exceed 2000 characters")
+				default:
+					errors = append(errors, e.Translate(trans))
+				}
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	// Optimistic concurrency check
+	expectedVersion, ok := expectedTaskVersion(c, task, input.Version)
+	if !ok {
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	// Claim the version so a concurrent update cannot overwrite this one
+	if bumped, err := bumpTaskVersion(tx, task.ID, expectedVersion); err != nil || !bumped {
+		tx.Rollback()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task progress"})
+			return
+		}
+		respondTaskVersionConflict(c, task.ID)
+		return
+	}
+
+	if err := recordTaskProgress(tx, task, authUserID, task.Status, input.Progress, input.Note); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task progress"})
+		return
+	}
+
+	// Create notification for task creator
+	if task.CreatedBy != authUserID {
+		var user models.User
+		database.DB.First(&user, authUserID)
+		notification := models.Notification{
+			UserID:  task.CreatedBy,
+			TaskID:  &task.ID,
+			Type:    "progress_update",
+			Message: fmt.Sprintf("Task '%s' progress updated to %d%% by %s", task.NotificationLabel(), *input.Progress, user.Username),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			// Handle error
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	task.Version = expectedVersion + 1
+	c.Header("ETag", taskETag(task))
+	c.JSON(http.StatusOK, gin.H{"message": "Task progress updated successfully", "version": task.Version})
+}
+
+// GetTaskProgressTimeline lists the status and progress updates of a task, oldest first
+func GetTaskProgressTimeline(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var logs []models.TaskStatusUpdateLog
+	if err := database.DB.Preload("User").Where("task_id = ?", task.ID).Order("created_at, id").Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve task progress"})
+		return
+	}
+
+	timeline := []ProgressTimelineEntry{}
+	previousStatus := ""
+	for _, log := range logs {
+		timeline = append(timeline, ProgressTimelineEntry{
+			ID:            log.ID,
+			UserID:        log.UserID,
+			Username:      log.User.Username,
+			Status:        log.Status,
+			StatusChanged: log.Status != previousStatus,
+			Progress:      log.Progress,
+			Note:          log.Note,
+			CreatedAt:     log.CreatedAt,
+		})
+		previousStatus = log.Status
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"progress": task.Progress, "status": task.Status, "timeline": timeline}})
+}
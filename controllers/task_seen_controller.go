@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeenByResponse is one entry of a task's read receipts
+type SeenByResponse struct {
+	UserID   uint      `json:"user_id"`
+	Username string    `json:"username"`
+	SeenAt   time.Time `json:"seen_at"`
+}
+
+// markTaskSeen records that a user has just looked at a task
+func markTaskSeen(db *gorm.DB, taskID uint, userID uint) error {
+	seen := models.TaskSeenByUser{TaskID: taskID, UserID: userID, SeenAt: time.Now()}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"seen_at", "updated_at"}),
+	}).Create(&seen).Error
+}
+
+// touchTaskActivity marks a task as having new activity, which makes it unread for everybody
+// who has seen it before. The actor has obviously seen their own change.
+func touchTaskActivity(tx *gorm.DB, taskID uint, actorID uint) error {
+	if err := tx.Model(&models.Task{}).Where("id = ?", taskID).UpdateColumn("last_activity_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return markTaskSeen(tx, taskID, actorID)
+}
+
+// annotateReadState sets the ReadState of each task for the given user
+func annotateReadState(db *gorm.DB, userID uint, tasks []models.Task) {
+	if len(tasks) == 0 {
+		return
+	}
+
+	var taskIDs []uint
+	for _, task := range tasks {
+		taskIDs = append(taskIDs, task.ID)
+	}
+
+	var seen []models.TaskSeenByUser
+	db.Where("user_id = ? AND task_id IN ?", userID, taskIDs).Find(&seen)
+	seenAt := make(map[uint]time.Time)
+	for _, s := range seen {
+		seenAt[s.TaskID] = s.SeenAt
+	}
+
+	for i := range tasks {
+		at, ok := seenAt[tasks[i].ID]
+		switch {
+		case !ok:
+			tasks[i].ReadState = "unseen"
+		case tasks[i].LastActivityAt != nil && tasks[i].LastActivityAt.After(at):
+			tasks[i].ReadState = "updated"
+		default:
+			tasks[i].ReadState = "seen"
+		}
+	}
+}
+
+// GetTaskSeenBy lists who has seen a task and when. Only the task creator can see it.
+func GetTaskSeenBy(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if task.CreatedBy != authUserID {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"Only the task creator can see who has seen this task"}})
+		return
+	}
+
+	var seen []models.TaskSeenByUser
+	if err := database.DB.Preload("User").Where("task_id = ?", task.ID).Order("seen_at DESC").Find(&seen).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve read receipts"})
+		return
+	}
+
+	seenBy := []SeenByResponse{}
+	for _, s := range seen {
+		seenBy = append(seenBy, SeenByResponse{UserID: s.UserID, Username: s.User.Username, SeenAt: s.SeenAt})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": seenBy})
+}
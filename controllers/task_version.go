@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// taskETag returns the entity tag of a task, which changes with every update
+func taskETag(task models.Task) string {
+	return fmt.Sprintf(`"task-%d-v%d"`, task.ID, task.Version)
+}
+
+// parseTaskETag extracts the version from an entity tag produced by taskETag
+func parseTaskETag(taskID uint, etag string) (uint, bool) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	prefix := fmt.Sprintf(`"task-%d-v`, taskID)
+	if !strings.HasPrefix(etag, prefix) || !strings.HasSuffix(etag, `"`) {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(etag, prefix), `"`), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(version), true
+}
+
+// loadTaskRepresentation loads a task the way GetTaskByID returns it
+func loadTaskRepresentation(db *gorm.DB, taskID interface{}) (models.Task, error) {
+	var task models.Task
+	err := db.Preload("AssignedUsers.User").Preload("AssignedGroups.Group.Users").Preload("FollowupUsers.User").Preload("FollowupGroups.Group.Users").Preload("Escalations").Preload("Subtasks").Preload("Tags").Preload("FieldValues.Field").Preload("Creator").Where("id = ?", taskID).First(&task).Error
+	return task, err
+}
+
+// respondTaskVersionConflict answers 412 with the current representation of the task
+func respondTaskVersionConflict(c *gin.Context, taskID uint) {
+	current, err := loadTaskRepresentation(database.DB, taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found"}})
+		return
+	}
+	authUserID := uint(c.MustGet("user_id").(float64))
+	renderTaskMarkdown(database.DB, authUserID, &current)
+
+	c.Header("ETag", taskETag(current))
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"errors": []string{"The task was changed by someone else. Reload it and try again"},
+		"data":   current,
+	})
+}
+
+// expectedTaskVersion reads the version the client based its change on, from the If-Match
+// header or the version field of the body. It writes a 428 or 412 response when it fails.
+func expectedTaskVersion(c *gin.Context, task models.Task, bodyVersion *uint) (uint, bool) {
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		version, ok := parseTaskETag(task.ID, ifMatch)
+		if !ok {
+			respondTaskVersionConflict(c, task.ID)
+			return 0, false
+		}
+		return version, true
+	}
+	if bodyVersion != nil {
+		return *bodyVersion, true
+	}
+	c.JSON(http.StatusPreconditionRequired, gin.H{"errors": []string{"An If-Match header or a version field is required to update a task"}})
+	return 0, false
+}
+
+// bumpTaskVersion increments the version of a task if it still has the expected version.
+// It returns false when somebody else updated the task in the meantime.
+func bumpTaskVersion(tx *gorm.DB, taskID uint, expected uint) (bool, error) {
+	result := tx.Model(&models.Task{}).
+		Where("id = ? AND version = ?", taskID, expected).
+		UpdateColumn("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// incrementTaskVersion increments the version of a task unconditionally, for changes that
+// do not come from an editor holding a copy of the task (timers, the scheduler)
+func incrementTaskVersion(tx *gorm.DB, taskID uint) error {
+	return tx.Model(&models.Task{}).Where("id = ?", taskID).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
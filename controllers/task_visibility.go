@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// taskRelation is how a user is related to a task, as far as seeing it is concerned
+type taskRelation struct {
+	Creator     bool // created the task
+	Named       bool // assigned to the task or a follow-up user of it
+	GroupMember bool // member of a group assigned to the task or following it up
+	SuperAdmin  bool
+}
+
+// taskVisibleTo is the visibility policy for reading a task and anything attached to it
+// (comments, attachment, history, worklogs). A task is visible to its creator, its direct
+// and group assignees, its follow-up users and groups, and super admins. Groups do not count
+// on restricted tasks, and private tasks are not visible to super admins either.
+func taskVisibleTo(relation taskRelation, confidentiality string) bool {
+	switch {
+	case relation.Creator, relation.Named:
+		return true
+	case relation.GroupMember && confidentiality == models.ConfidentialityNormal:
+		return true
+	case relation.SuperAdmin && confidentiality != models.ConfidentialityPrivate:
+		return true
+	}
+	return false
+}
+
+// taskRelationOf looks up how a user is related to a task
+func taskRelationOf(db *gorm.DB, userID uint, task models.Task) (taskRelation, error) {
+	relation := taskRelation{Creator: task.CreatedBy == userID}
+
+	var count int64
+	// A declined assignment does not name the user
+	err := db.Model(&models.AssignTaskToUser{}).Where("task_id = ? AND user_id = ? AND status <> ?", task.ID, userID, models.AssignmentDeclined).Count(&count).Error
+	if err != nil {
+		return relation, err
+	}
+	if count == 0 {
+		err = db.Model(&models.TaskFollowupUser{}).Where("task_id = ? AND user_id = ?", task.ID, userID).Count(&count).Error
+		if err != nil {
+			return relation, err
+		}
+	}
+	relation.Named = count > 0
+
+	err = db.Model(&models.UserGroup{}).
+		Where("user_groups.user_id = ?", userID).
+		Where("user_groups.group_id IN (?) OR user_groups.group_id IN (?)",
+			db.Model(&models.AssignTaskToGroup{}).Select("group_id").Where("task_id = ?", task.ID),
+			db.Model(&models.TaskFollowupGroup{}).Select("group_id").Where("task_id = ?", task.ID)).
+		Count(&count).Error
+	if err != nil {
+		return relation, err
+	}
+	relation.GroupMember = count > 0
+
+	relation.SuperAdmin, err = isSuperAdmin(db, userID)
+	return relation, err
+}
+
+// canViewTask checks the visibility policy of taskVisibleTo for a user
+func canViewTask(db *gorm.DB, userID uint, task models.Task) (bool, error) {
+	if task.CreatedBy == userID {
+		return true, nil
+	}
+	relation, err := taskRelationOf(db, userID, task)
+	if err != nil {
+		return false, err
+	}
+	return taskVisibleTo(relation, task.Confidentiality), nil
+}
+
+// findVisibleTask loads a task for the authenticated user. Tasks the user cannot see are
+// answered with 404 like missing ones, so their IDs cannot be probed.
+func findVisibleTask(c *gin.Context, id interface{}) (models.Task, bool) {
+	var task models.Task
+	if err := database.DB.Where("id = ?", id).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found"}})
+		return task, false
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	visible, err := canViewTask(database.DB, authUserID, task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check task visibility"})
+		return task, false
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found"}})
+		return task, false
+	}
+	return task, true
+}
+
+// GetTaskAttachment serves the attachment of a task to the users who can see the task
+func GetTaskAttachment(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if task.Attachment == "" {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task has no attachment"}})
+		return
+	}
+
+	path, ok := uploadedFile(task.Attachment)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task has no attachment"}})
+		return
+	}
+	c.File(path)
+}
+
+// GetTaskMailAttachment serves a file received by mail with a task or a reply to it, to the
+// users who can see the task
+func GetTaskMailAttachment(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	path, ok := uploadedFile(filepath.Join("uploads", c.Param("file")))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Attachment not found"}})
+		return
+	}
+
+	var lists []string
+	if err := database.DB.Model(&models.InboundMail{}).
+		Where("task_id = ? AND status <> ?", task.ID, models.InboundMailRejected).
+		Pluck("attachments", &lists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attachments"})
+		return
+	}
+	for _, list := range lists {
+		for _, attachment := range strings.Split(list, "\n") {
+			if attachment != "" && filepath.Clean(attachment) == path {
+				c.File(path)
+				return
+			}
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Attachment not found"}})
+}
+
+// visibleTasksQuery returns a task query restricted to the tasks canViewTask allows the user to see
+func visibleTasksQuery(db *gorm.DB, userID uint) (*gorm.DB, error) {
+	admin, err := isSuperAdmin(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !admin {
+		return myTasksQuery(db, userID)
+	}
+	relevantTaskIDs, err := myTaskIDs(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	query := db.Model(&models.Task{})
+	if len(relevantTaskIDs) > 0 {
+		return query.Where("created_by = ? OR id IN ? OR confidentiality <> ?", userID, relevantTaskIDs, models.ConfidentialityPrivate), nil
+	}
+	return query.Where("created_by = ? OR confidentiality <> ?", userID, models.ConfidentialityPrivate), nil
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"testing"
+
+	"taskmanager/models"
+)
+
+func TestTaskVisibleTo(t *testing.T) {
+	normal, restricted, private := models.ConfidentialityNormal, models.ConfidentialityRestricted, models.ConfidentialityPrivate
+
+	tests := []struct {
+		name     string
+		relation taskRelation
+		visible  map[string]bool
+	}{
+		{"creator", taskRelation{Creator: true}, map[string]bool{normal: true, restricted: true, private: true}},
+		{"assignee or follow-up user", taskRelation{Named: true}, map[string]bool{normal: true, restricted: true, private: true}},
+		{"group member", taskRelation{GroupMember: true}, map[string]bool{normal: true, restricted: false, private: false}},
+		{"super admin", taskRelation{SuperAdmin: true}, map[string]bool{normal: true, restricted: true, private: false}},
+		{"super admin in a group", taskRelation{SuperAdmin: true, GroupMember: true}, map[string]bool{normal: true, restricted: true, private: false}},
+		{"named super admin", taskRelation{SuperAdmin: true, Named: true}, map[string]bool{normal: true, restricted: true, private: true}},
+		{"unrelated user", taskRelation{}, map[string]bool{normal: false, restricted: false, private: false}},
+	}
+
+	for _, tt := range tests {
+		for confidentiality, want := range tt.visible {
+			if got := taskVisibleTo(tt.relation, confidentiality); got != want {
+				t.Errorf("%s, %s task: visible = %v, want %v", tt.name, confidentiality, got, want)
+			}
+		}
+	}
+}
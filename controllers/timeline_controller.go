@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimelineAssignee is a user assigned to a timeline task
+type TimelineAssignee struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Status   string `json:"status"` // assignment state: pending, accepted or declined
+}
+
+// TimelineGroup is a group assigned to a timeline task
+type TimelineGroup struct {
+	ID    uint   `json:"id"`
+	Label string `json:"label"`
+}
+
+// TimelineTask is a task as drawn on the timeline, with its schedule figures
+type TimelineTask struct {
+	ID           uint               `json:"id"`
+	Label        string             `json:"label"`
+	ParentID     *uint              `json:"parent_id"`
+	Status       string             `json:"status"`
+	Priority     string             `json:"priority"`
+	Progress     uint               `json:"progress"`
+	StartDate    time.Time          `json:"start_date"`
+	DueDate      *time.Time         `json:"due_date"`
+	DurationDays int                `json:"duration_days"` // inclusive of start and end day
+	Overdue      bool               `json:"overdue"`
+	SlackDays    int                `json:"slack_days"` // days the task can slip without delaying the timeline, negative if the schedule is infeasible
+	Critical     bool               `json:"critical"`   // on the critical path: no slack
+	Assignees    []TimelineAssignee `json:"assignees"`
+	Groups       []TimelineGroup    `json:"groups"`
+	DependsOn    []uint             `json:"depends_on"`
+	Subtasks     []*TimelineTask    `json:"subtasks"`
+
+	start, finish int // day numbers of the start and of the end of the task
+}
+
+// TimelineDependency is a finish-to-start edge between two timeline tasks
+type TimelineDependency struct {
+	TaskID      uint `json:"task_id"`
+	DependsOnID uint `json:"depends_on_id"`
+}
+
+// dayNumber converts a date to a count of days, ignoring time of day and time zone
+func dayNumber(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// scheduleTimeline computes slack and critical path membership with the critical path
+// method. Tasks keep their planned dates; the latest finish of a task is the day before the
+// latest start of its earliest dependent, or the end of the timeline. Edges must be acyclic.
+func scheduleTimeline(tasks map[uint]*TimelineTask, edges []TimelineDependency) {
+	successors := make(map[uint][]uint)
+	pending := make(map[uint]int) // number of successors not yet scheduled
+	end := 0
+	for id, task := range tasks {
+		pending[id] = 0
+		if task.finish > end {
+			end = task.finish
+		}
+	}
+	for _, edge := range edges {
+		successors[edge.DependsOnID] = append(successors[edge.DependsOnID], edge.TaskID)
+		pending[edge.DependsOnID]++
+	}
+
+	// Backward pass, from the tasks nothing depends on
+	var queue []uint
+	for id, n := range pending {
+		if n == 0 {
+			queue = append(queue, id)
+		}
+	}
+	latestStart := make(map[uint]int)
+	predecessors := make(map[uint][]uint)
+	for _, edge := range edges {
+		predecessors[edge.TaskID] = append(predecessors[edge.TaskID], edge.DependsOnID)
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		task := tasks[id]
+
+		latestFinish := end
+		for _, succ := range successors[id] {
+			if latestStart[succ]-1 < latestFinish {
+				latestFinish = latestStart[succ] - 1
+			}
+		}
+		task.SlackDays = latestFinish - task.finish
+		task.Critical = task.SlackDays <= 0
+		latestStart[id] = latestFinish - (task.finish - task.start)
+
+		for _, pred := range predecessors[id] {
+			pending[pred]--
+			if pending[pred] == 0 {
+				queue = append(queue, pred)
+			}
+		}
+	}
+}
+
+// GetTimeline returns the data of a Gantt chart in one call: the visible tasks within a
+// date window, nested by subtask, with their assignees, the dependency edges between them,
+// and the computed overdue, slack and critical path figures. The window and the group or
+// assignee filter are optional query parameters (from, to, group_id, assignee_id).
+func GetTimeline(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	query, err := visibleTasksQuery(database.DB, authUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user groups"})
+		return
+	}
+
+	// Tasks overlapping the window; a task without due date lasts its start day
+	if from != nil {
+		query = query.Where("COALESCE(due_date, start_date) >= ?", from.Format("2006-01-02"))
+	}
+	if to != nil {
+		query = query.Where("start_date <= ?", to.Format("2006-01-02"))
+	}
+
+	if s := c.Query("group_id"); s != "" {
+		var group models.Group
+		if err := database.DB.First(&group, s).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Group not found"}})
+			return
+		}
+		if group.CreatedBy != authUserID {
+			var membership int64
+			if err := database.DB.Model(&models.UserGroup{}).Where("group_id = ? AND user_id = ?", group.ID, authUserID).Count(&membership).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check group membership"})
+				return
+			}
+			admin, err := isSuperAdmin(database.DB, authUserID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+				return
+			}
+			if membership == 0 && !admin {
+				c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to view the timeline of this group"}})
+				return
+			}
+		}
+
+		// The group's work: tasks assigned to the group or to one of its members
+		members := database.DB.Model(&models.UserGroup{}).Select("user_id").Where("group_id = ?", group.ID)
+		assignedToGroup := database.DB.Model(&models.AssignTaskToGroup{}).Select("task_id").Where("group_id = ?", group.ID)
+		assignedToMember := database.DB.Model(&models.AssignTaskToUser{}).Select("task_id").Where("user_id IN (?) AND status <> ?", members, models.AssignmentDeclined)
+		query = query.Where("id IN (?) OR id IN (?)", assignedToGroup, assignedToMember)
+	}
+
+	if s := c.Query("assignee_id"); s != "" {
+		assigneeID, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid assignee_id"}})
+			return
+		}
+		assigned := database.DB.Model(&models.AssignTaskToUser{}).Select("task_id").Where("user_id = ? AND status <> ?", assigneeID, models.AssignmentDeclined)
+		query = query.Where("id IN (?)", assigned)
+	}
+
+	var tasks []models.Task
+	if err := query.
+		Preload("AssignedUsers.User").
+		Preload("AssignedGroups.Group").
+		Order("start_date, id").
+		Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
+		return
+	}
+
+	today := dayNumber(time.Now())
+	byID := make(map[uint]*TimelineTask, len(tasks))
+	taskIDs := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		item := &TimelineTask{
+			ID:        task.ID,
+			Label:     task.Label,
+			ParentID:  task.ParentID,
+			Status:    task.Status,
+			Priority:  task.Priority,
+			Progress:  task.Progress,
+			StartDate: task.StartDate,
+			DueDate:   task.DueDate,
+			Assignees: []TimelineAssignee{},
+			Groups:    []TimelineGroup{},
+			DependsOn: []uint{},
+			Subtasks:  []*TimelineTask{},
+			start:     dayNumber(task.StartDate),
+		}
+		item.finish = item.start
+		if task.DueDate != nil && dayNumber(*task.DueDate) > item.start {
+			item.finish = dayNumber(*task.DueDate)
+		}
+		item.DurationDays = item.finish - item.start + 1
+		item.Overdue = task.Status != "Completed" && task.DueDate != nil && dayNumber(*task.DueDate) < today
+		for _, a := range task.AssignedUsers {
+			item.Assignees = append(item.Assignees, TimelineAssignee{ID: a.UserID, Username: a.User.Username, Status: a.Status})
+		}
+		for _, g := range task.AssignedGroups {
+			item.Groups = append(item.Groups, TimelineGroup{ID: g.GroupID, Label: g.Group.Label})
+		}
+		byID[task.ID] = item
+		taskIDs = append(taskIDs, task.ID)
+	}
+
+	// Only edges between tasks of the timeline are drawn and scheduled
+	edges := []TimelineDependency{}
+	if len(taskIDs) > 0 {
+		var dependencies []models.TaskDependency
+		if err := database.DB.Where("task_id IN ? AND depends_on_id IN ?", taskIDs, taskIDs).Order("task_id, depends_on_id").Find(&dependencies).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dependencies"})
+			return
+		}
+		for _, d := range dependencies {
+			edges = append(edges, TimelineDependency{TaskID: d.TaskID, DependsOnID: d.DependsOnID})
+			byID[d.TaskID].DependsOn = append(byID[d.TaskID].DependsOn, d.DependsOnID)
+		}
+	}
+	scheduleTimeline(byID, edges)
+
+	// Nest subtasks under their parent when the parent is on the timeline too
+	roots := []*TimelineTask{}
+	criticalPath := []uint{}
+	for _, id := range taskIDs {
+		item := byID[id]
+		if item.Critical {
+			criticalPath = append(criticalPath, id)
+		}
+		if item.ParentID != nil {
+			if parent, ok := byID[*item.ParentID]; ok {
+				parent.Subtasks = append(parent.Subtasks, item)
+				continue
+			}
+		}
+		roots = append(roots, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"tasks":         roots,
+		"dependencies":  edges,
+		"critical_path": criticalPath, // in start date order
+	}})
+}
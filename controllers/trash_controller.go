@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PurgeTrashInput struct {
+	RetentionDays *int `json:"retention_days" binding:"omitempty,gte=0"`
+}
+
+// defaultTrashRetentionDays is how long trashed tasks are kept when no retention is given
+const defaultTrashRetentionDays = 30
+
+// taskChildModels lists the soft-deletable records that belong to a task
+func taskChildModels() []interface{} {
+	return []interface{}{
+		&models.AssignTaskToUser{},
+		&models.AssignTaskToGroup{},
+		&models.TaskFollowupUser{},
+		&models.TaskFollowupGroup{},
+		&models.TaskStatusUpdateLog{},
+		&models.TaskCommentLog{},
+		&models.TaskSeenByUser{},
+		&models.TaskWorklog{},
+		&models.TaskEscalationLog{},
+		&models.TaskFieldValue{},
+		&models.TaskHistory{},
+		&models.TaskDependency{},
+	}
+}
+
+// trashTask soft-deletes a task and its child records with a shared timestamp,
+// so that a restore brings back exactly the records that were trashed together
+func trashTask(tx *gorm.DB, task *models.Task, userID uint) error {
+	now := time.Now()
+
+	// Logged before the children are trashed so the entry is restored along with them
+	changes := newTaskChangeLog(task.ID, userID)
+	changes.add("deleted", "false", "true")
+	if err := changes.save(tx); err != nil {
+		return err
+	}
+
+	// A running timer cannot survive in the trash, it would block the user from starting
+	// another one. It is stopped, keeping the tracked time, and trashed with the other worklogs.
+	var running []models.TaskWorklog
+	if err := tx.Where("task_id = ? AND ended_at IS NULL", task.ID).Find(&running).Error; err != nil {
+		return err
+	}
+	for i := range running {
+		if _, err := stopWorklog(tx, &running[i], userID, running[i].Note, now); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range taskChildModels() {
+		if err := tx.Model(child).Where("task_id = ?", task.ID).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+	}
+	// Dependencies on the task go too, a task nobody can see should not block its dependents
+	if err := tx.Model(&models.TaskDependency{}).Where("depends_on_id = ?", task.ID).Update("deleted_at", now).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Task{}).Where("id = ?", task.ID).Update("deleted_at", now).Error
+}
+
+// GetTrash lists the trashed tasks created by the authenticated user
+func GetTrash(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var tasks []models.Task
+	if err := database.DB.Unscoped().
+		Where("created_by = ? AND deleted_at IS NOT NULL", authUserID).
+		Order("deleted_at DESC").
+		Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve trash"})
+		return
+	}
+
+	renderTasksMarkdown(database.DB, authUserID, tasks)
+
+	c.JSON(http.StatusOK, gin.H{"data": tasks})
+}
+
+// RestoreTask brings a trashed task and its child records back
+func RestoreTask(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+
+	if err := database.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found in trash"}})
+		return
+	}
+
+	authUserID := uint(c.MustGet("user_id").(float64))
+	if task.CreatedBy != authUserID {
+		admin, err := isSuperAdmin(database.DB, authUserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+			return
+		}
+		if !admin {
+			c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to restore this task"}})
+			return
+		}
+	}
+
+	deletedAt := task.DeletedAt.Time
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, child := range taskChildModels() {
+			if err := tx.Unscoped().Model(child).Where("task_id = ? AND deleted_at = ?", task.ID, deletedAt).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Model(&models.TaskDependency{}).Where("depends_on_id = ? AND deleted_at = ?", task.ID, deletedAt).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&task).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		changes := newTaskChangeLog(task.ID, authUserID)
+		changes.add("deleted", "true", "false")
+		return changes.save(tx)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore task"})
+		return
+	}
+
+	renderTaskMarkdown(database.DB, authUserID, &task)
+
+	c.JSON(http.StatusOK, gin.H{"data": task})
+}
+
+// PurgeTrash permanently removes tasks that have been in the trash longer than the retention period
+func PurgeTrash(c *gin.Context) {
+	if !requireSuperAdmin(c, "Only a super admin can purge the trash") {
+		return
+	}
+
+	var input PurgeTrashInput
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	retentionDays := defaultTrashRetentionDays
+	if input.RetentionDays != nil {
+		retentionDays = *input.RetentionDays
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	var taskIDs []uint
+	if err := database.DB.Unscoped().Model(&models.Task{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Pluck("id", &taskIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve trashed tasks"})
+		return
+	}
+
+	if len(taskIDs) > 0 {
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// Revisions and reactions belong to the comments of the purged tasks
+			commentIDs := tx.Unscoped().Model(&models.TaskCommentLog{}).Select("id").Where("task_id IN ?", taskIDs)
+			for _, model := range []interface{}{&models.TaskCommentRevision{}, &models.TaskCommentReaction{}} {
+				if err := tx.Where("comment_id IN (?)", commentIDs).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+
+			// Replies reference their parent comment, so they go first
+			if err := tx.Unscoped().Where("task_id IN ? AND parent_id IS NOT NULL", taskIDs).Delete(&models.TaskCommentLog{}).Error; err != nil {
+				return err
+			}
+
+			purgeable := append(taskChildModels(), &models.TaskReminder{}, &models.Notification{})
+			for _, child := range purgeable {
+				if err := tx.Unscoped().Where("task_id IN ?", taskIDs).Delete(child).Error; err != nil {
+					return err
+				}
+			}
+			// Dependencies of other tasks on the purged ones
+			if err := tx.Unscoped().Where("depends_on_id IN ?", taskIDs).Delete(&models.TaskDependency{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM task_tags WHERE task_id IN ?", taskIDs).Error; err != nil {
+				return err
+			}
+			// Subtasks outlive their purged parent, as top-level tasks
+			if err := tx.Unscoped().Model(&models.Task{}).Where("parent_id IN ?", taskIDs).UpdateColumn("parent_id", nil).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Where("id IN ?", taskIDs).Delete(&models.Task{}).Error
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge trash"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Trash purged successfully", "purged": len(taskIDs)})
+}
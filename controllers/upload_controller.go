@@ -3,12 +3,55 @@ package controllers
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// uploadPath returns a unique path in the 'uploads' directory for a file name
+func uploadPath(name string) string {
+	// Generate a unique filename to prevent collisions
+	filename := fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(name))
+
+	// Assuming 'uploads' directory is at the root of the application
+	return filepath.Join("uploads", filename)
+}
+
+// uploadedFile resolves the attachment of a task to its file in the 'uploads' directory.
+// Paths that lead anywhere else are refused.
+func uploadedFile(attachment string) (string, bool) {
+	path := filepath.Clean(filepath.FromSlash(attachment))
+	if filepath.IsAbs(path) || filepath.Dir(path) != "uploads" {
+		return "", false
+	}
+	return path, true
+}
+
+// validAttachment checks the attachment given for a task: none, or a file uploaded with
+// UploadAttachment
+func validAttachment(attachment string) bool {
+	if attachment == "" {
+		return true
+	}
+	_, ok := uploadedFile(attachment)
+	return ok
+}
+
+// saveUpload stores content received other than by a form upload, e.g. a mail attachment,
+// and returns its path
+func saveUpload(name string, content []byte) (string, error) {
+	savePath := uploadPath(name)
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(savePath, content, 0644); err != nil {
+		return "", err
+	}
+	return savePath, nil
+}
+
 // UploadAttachment handles file uploads to the 'uploads' directory.
 func UploadAttachment(c *gin.Context) {
 	file, err := c.FormFile("attachment")
@@ -17,12 +60,8 @@ func UploadAttachment(c *gin.Context) {
 		return
 	}
 
-	// Generate a unique filename to prevent collisions
-	filename := fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(file.Filename))
-	
 	// Define the path to save the file
-	// Assuming 'uploads' directory is at the root of the application
-	savePath := filepath.Join("uploads", filename)
+	savePath := uploadPath(file.Filename)
 
 	// Save the file
 	if err := c.SaveUploadedFile(file, savePath); err != nil {
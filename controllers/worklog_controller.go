@@ -0,0 +1,486 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"taskmanager/database"
+	"taskmanager/models"
+	"taskmanager/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StopTimerInput struct {
+	Note string `json:"note"`
+}
+
+type AddWorklogInput struct {
+	Minutes uint       `json:"minutes" binding:"required,gt=0"`
+	Date    utils.Date `json:"date"`
+	Note    string     `json:"note"`
+}
+
+// TimeTotal is a single row of an aggregated time report
+type TimeTotal struct {
+	UserID  uint   `json:"user_id,omitempty"`
+	TaskID  uint   `json:"task_id,omitempty"`
+	Label   string `json:"label,omitempty"`
+	Minutes uint   `json:"minutes"`
+}
+
+// parseDateRange reads optional "from" and "to" query parameters in YYYY-MM-DD format.
+// The returned "to" is moved to the end of that day so the whole day is included.
+func parseDateRange(c *gin.Context) (*time.Time, *time.Time, bool) {
+	var from, to *time.Time
+	if s := c.Query("from"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid from date. Use YYYY-MM-DD"}})
+			return nil, nil, false
+		}
+		from = &t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Invalid to date. Use YYYY-MM-DD"}})
+			return nil, nil, false
+		}
+		endOfDay := t.Add(24 * time.Hour).Add(-time.Second)
+		to = &endOfDay
+	}
+	return from, to, true
+}
+
+// applyWorklogRange restricts a worklog query to finished entries started within the range
+func applyWorklogRange(db *gorm.DB, from, to *time.Time) *gorm.DB {
+	db = db.Where("task_worklogs.ended_at IS NOT NULL")
+	if from != nil {
+		db = db.Where("task_worklogs.started_at >= ?", *from)
+	}
+	if to != nil {
+		db = db.Where("task_worklogs.started_at <= ?", *to)
+	}
+	return db
+}
+
+// adjustRemainingEstimate subtracts logged minutes from a task's remaining estimate.
+// A negative delta gives time back, e.g. when a worklog is deleted.
+func adjustRemainingEstimate(tx *gorm.DB, taskID uint, userID uint, delta int) error {
+	var task models.Task
+	if err := tx.Select("id", "remaining_estimate").First(&task, taskID).Error; err != nil {
+		return err
+	}
+	if task.RemainingEstimate == nil || delta == 0 {
+		return nil
+	}
+
+	remaining := int(*task.RemainingEstimate) - delta
+	if remaining < 0 {
+		remaining = 0
+	}
+	if err := tx.Model(&models.Task{}).Where("id = ?", taskID).Update("remaining_estimate", remaining).Error; err != nil {
+		return err
+	}
+
+	if err := incrementTaskVersion(tx, taskID); err != nil {
+		return err
+	}
+
+	changes := newTaskChangeLog(taskID, userID)
+	changes.add("remaining_estimate", formatID(*task.RemainingEstimate), strconv.Itoa(remaining))
+	return changes.save(tx)
+}
+
+// stopWorklog stops a running timer at now and subtracts its time from the remaining
+// estimate. It reports false, and changes nothing, when the timer was already stopped,
+// e.g. by a concurrent request.
+func stopWorklog(tx *gorm.DB, worklog *models.TaskWorklog, userID uint, note string, now time.Time) (bool, error) {
+	minutes := uint(math.Round(now.Sub(worklog.StartedAt).Minutes()))
+
+	result := tx.Model(&models.TaskWorklog{}).Where("id = ? AND ended_at IS NULL", worklog.ID).Updates(map[string]interface{}{
+		"ended_at": now,
+		"minutes":  minutes,
+		"note":     note,
+	})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false, result.Error
+	}
+	worklog.EndedAt = &now
+	worklog.Minutes = minutes
+	worklog.Note = note
+
+	return true, adjustRemainingEstimate(tx, worklog.TaskID, userID, int(minutes))
+}
+
+// loadTaskForTimeTracking loads the task and checks the user is allowed to log time on it
+func loadTaskForTimeTracking(c *gin.Context, authUserID uint) (*models.Task, bool) {
+	var task models.Task
+	if err := database.DB.First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Task not found"}})
+		return nil, false
+	}
+
+	allowed, err := isUserAssignedOrFollowup(database.DB, authUserID, task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user assignment"})
+		return nil, false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to log time on this task"}})
+		return nil, false
+	}
+
+	return &task, true
+}
+
+// StartTaskTimer starts a timer on a task for the authenticated user
+func StartTaskTimer(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	task, ok := loadTaskForTimeTracking(c, authUserID)
+	if !ok {
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	// Lock the user row so concurrent starts cannot both see "no running timer"
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, authUserID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start timer"})
+		return
+	}
+
+	var running models.TaskWorklog
+	if err := tx.Where("user_id = ? AND ended_at IS NULL", authUserID).First(&running).Error; err == nil {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"errors": []string{"You already have a running timer"}, "data": running})
+		return
+	}
+
+	worklog := models.TaskWorklog{
+		TaskID:    task.ID,
+		UserID:    authUserID,
+		StartedAt: time.Now(),
+	}
+	if err := tx.Create(&worklog).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start timer"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": worklog})
+}
+
+// StopTimer stops the authenticated user's running timer and turns it into a worklog
+func StopTimer(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var input StopTimerInput
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	var worklog models.TaskWorklog
+	if err := database.DB.Where("user_id = ? AND ended_at IS NULL", authUserID).First(&worklog).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"No running timer"}})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	// Only the request that actually stops the timer charges its time to the estimate
+	stopped, err := stopWorklog(tx, &worklog, authUserID, input.Note, time.Now())
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop timer"})
+		return
+	}
+	if !stopped {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"No running timer"}})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": worklog})
+}
+
+// GetRunningTimer returns the authenticated user's running timer, if any
+func GetRunningTimer(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var worklog models.TaskWorklog
+	if err := database.DB.Where("user_id = ? AND ended_at IS NULL", authUserID).First(&worklog).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"data": nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": worklog})
+}
+
+// AddTaskWorklog adds a manual worklog entry to a task
+func AddTaskWorklog(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	task, ok := loadTaskForTimeTracking(c, authUserID)
+	if !ok {
+		return
+	}
+
+	var input AddWorklogInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	startedAt := input.Date.Time
+	if startedAt.IsZero() {
+		startedAt = time.Now()
+	}
+	endedAt := startedAt.Add(time.Duration(input.Minutes) * time.Minute)
+
+	worklog := models.TaskWorklog{
+		TaskID:    task.ID,
+		UserID:    authUserID,
+		StartedAt: startedAt,
+		EndedAt:   &endedAt,
+		Minutes:   input.Minutes,
+		Note:      input.Note,
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&worklog).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add worklog"})
+		return
+	}
+
+	if err := adjustRemainingEstimate(tx, task.ID, authUserID, int(input.Minutes)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update remaining estimate"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": worklog})
+}
+
+// GetTaskWorklogs lists the worklogs of a task
+func GetTaskWorklogs(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var worklogs []models.TaskWorklog
+	if err := database.DB.Preload("User").Where("task_id = ?", task.ID).Order("started_at DESC").Find(&worklogs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve worklogs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": worklogs})
+}
+
+// DeleteWorklog deletes a worklog owned by the authenticated user
+func DeleteWorklog(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var worklog models.TaskWorklog
+	if err := database.DB.First(&worklog, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Worklog not found"}})
+		return
+	}
+
+	if worklog.UserID != authUserID {
+		c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to delete this worklog"}})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Unscoped().Delete(&worklog).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete worklog"})
+		return
+	}
+
+	if worklog.EndedAt != nil {
+		if err := adjustRemainingEstimate(tx, worklog.TaskID, authUserID, -int(worklog.Minutes)); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update remaining estimate"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Worklog deleted successfully"})
+}
+
+// GetTaskTimeTotals returns the time logged on a task, broken down per user
+func GetTaskTimeTotals(c *gin.Context) {
+	task, ok := findVisibleTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var rows []TimeTotal
+	if err := applyWorklogRange(database.DB.Model(&models.TaskWorklog{}), from, to).
+		Select("task_worklogs.user_id, users.username AS label, SUM(task_worklogs.minutes) AS minutes").
+		Joins("JOIN users ON users.id = task_worklogs.user_id").
+		Where("task_worklogs.task_id = ?", task.ID).
+		Group("task_worklogs.user_id, users.username").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate time totals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"task_id":            task.ID,
+		"total_minutes":      sumTimeTotals(rows),
+		"original_estimate":  task.OriginalEstimate,
+		"remaining_estimate": task.RemainingEstimate,
+		"users":              nonNilTimeTotals(rows),
+	}})
+}
+
+// GetUserTimeTotals returns the time logged by a user, broken down per task
+func GetUserTimeTotals(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var user models.User
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"User not found"}})
+		return
+	}
+
+	if user.ID != authUserID {
+		admin, err := isSuperAdmin(database.DB, authUserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+			return
+		}
+		if !admin {
+			c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to view time totals of this user"}})
+			return
+		}
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var rows []TimeTotal
+	if err := applyWorklogRange(database.DB.Model(&models.TaskWorklog{}), from, to).
+		Select("task_worklogs.task_id, tasks.label, SUM(task_worklogs.minutes) AS minutes").
+		Joins("JOIN tasks ON tasks.id = task_worklogs.task_id").
+		Where("task_worklogs.user_id = ?", user.ID).
+		Group("task_worklogs.task_id, tasks.label").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate time totals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"user_id":       user.ID,
+		"total_minutes": sumTimeTotals(rows),
+		"tasks":         nonNilTimeTotals(rows),
+	}})
+}
+
+// GetGroupTimeTotals returns the time logged by the members of a group, broken down per user
+func GetGroupTimeTotals(c *gin.Context) {
+	authUserID := uint(c.MustGet("user_id").(float64))
+
+	var group models.Group
+	if err := database.DB.First(&group, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errors": []string{"Group not found"}})
+		return
+	}
+
+	if group.CreatedBy != authUserID {
+		var membership int64
+		if err := database.DB.Model(&models.UserGroup{}).Where("group_id = ? AND user_id = ?", group.ID, authUserID).Count(&membership).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check group membership"})
+			return
+		}
+		admin, err := isSuperAdmin(database.DB, authUserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role"})
+			return
+		}
+		if membership == 0 && !admin {
+			c.JSON(http.StatusForbidden, gin.H{"errors": []string{"You are not authorized to view time totals of this group"}})
+			return
+		}
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var rows []TimeTotal
+	if err := applyWorklogRange(database.DB.Model(&models.TaskWorklog{}), from, to).
+		Select("task_worklogs.user_id, users.username AS label, SUM(task_worklogs.minutes) AS minutes").
+		Joins("JOIN users ON users.id = task_worklogs.user_id").
+		Joins("JOIN user_groups ON user_groups.user_id = task_worklogs.user_id").
+		Where("user_groups.group_id = ?", group.ID).
+		Group("task_worklogs.user_id, users.username").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate time totals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"group_id":      group.ID,
+		"total_minutes": sumTimeTotals(rows),
+		"users":         nonNilTimeTotals(rows),
+	}})
+}
+
+func sumTimeTotals(rows []TimeTotal) uint {
+	var total uint
+	for _, row := range rows {
+		total += row.Minutes
+	}
+	return total
+}
+
+func nonNilTimeTotals(rows []TimeTotal) []TimeTotal {
+	if rows == nil {
+		return []TimeTotal{}
+	}
+	return rows
+}
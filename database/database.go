@@ -20,6 +20,9 @@ func ConnectDatabase() {
 		log.Fatal("Failed to connect to database! \n" + err.Error())
 	}
 
+	// Assignments made before they could be accepted are treated as accepted
+	backfillAssignmentStatus := !database.Migrator().HasColumn(&models.AssignTaskToUser{}, "Status")
+
 			database.AutoMigrate(
 		&models.User{},
 		&models.Group{},
@@ -32,10 +35,30 @@ func ConnectDatabase() {
 		&models.TaskFollowupGroup{},
 		&models.TaskStatusUpdateLog{},
 		&models.TaskCommentLog{},
+		&models.TaskCommentRevision{},
+		&models.TaskCommentReaction{},
 		&models.TaskSeenByUser{},
 		&models.Notification{},
+		&models.TaskWorklog{},
+		&models.TaskReminder{},
+		&models.TaskEscalationLog{},
+		&models.Tag{},
+		&models.TaskTypeField{},
+		&models.TaskFieldValue{},
+		&models.TaskHistory{},
+		&models.CalendarFeed{},
+		&models.TaskDependency{},
+		&models.SavedView{},
+		&models.DefaultSavedView{},
+		&models.ImportJob{},
+		&models.MailSender{},
+		&models.InboundMail{},
 	)
 
+	if backfillAssignmentStatus {
+		database.Unscoped().Model(&models.AssignTaskToUser{}).Where("1 = 1").Update("status", models.AssignmentAccepted)
+	}
+
 	DB = database
 }
 
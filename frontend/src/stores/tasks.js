@@ -61,6 +61,10 @@ export const useTasksStore = defineStore('tasks', () => {
       await fetchTasks(); // Re-fetch to update the list
     } catch (e) {
       console.error(`Failed to update task ${taskId}:`, e);
+      // 412: someone else changed the task since it was loaded
+      if (e.response && e.response.status === 412) {
+        throw new Error(e.response.data.errors[0]);
+      }
       throw new Error('Task update failed on the server.');
     }
   }
@@ -70,7 +74,8 @@ export const useTasksStore = defineStore('tasks', () => {
     error.value = null;
     try {
       const response = await apiClient.get(`/tasks/${id}`);
-      return response.data.data; // Return the full task object
+      // The task has the first page of comment threads, keep the paging to load the rest
+      return { ...response.data.data, CommentsPagination: response.data.comments_pagination }; // Return the full task object
     } catch (e) {
       error.value = `Failed to fetch task with ID ${id}.`;
       console.error(e);
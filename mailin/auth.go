@@ -0,0 +1,74 @@
+package mailin
+
+import (
+	"regexp"
+	"strings"
+)
+
+var authCommentPattern = regexp.MustCompile(`\([^()]*\)`)
+
+// SenderAuthenticated reports whether the upstream mail server trusted with AuthServID
+// verified that a message comes from the domain of its From address: DKIM passed for an
+// aligned signing domain, SPF passed for an aligned envelope sender, or DMARC passed.
+// Only Authentication-Results headers of that server count. It must remove headers with
+// its own authserv-id from the messages it receives, as RFC 8601 requires, so that
+// senders cannot add their own.
+func (c Config) SenderAuthenticated(p *Parsed) bool {
+	if c.AuthServID == "" {
+		return false
+	}
+	at := strings.LastIndex(p.From, "@")
+	if at < 0 {
+		return false
+	}
+	fromDomain := p.From[at+1:]
+
+	for _, header := range p.AuthResults {
+		parts := strings.Split(authCommentPattern.ReplaceAllString(header, " "), ";")
+		// The authserv-id may be followed by a version
+		if fields := strings.Fields(parts[0]); len(fields) == 0 || !strings.EqualFold(fields[0], c.AuthServID) {
+			continue
+		}
+		for _, part := range parts[1:] {
+			fields := strings.Fields(strings.ToLower(part))
+			if len(fields) == 0 {
+				continue
+			}
+			props := make(map[string]string)
+			for _, field := range fields[1:] {
+				if key, value, ok := strings.Cut(field, "="); ok {
+					props[key] = strings.Trim(value, `"`)
+				}
+			}
+			switch fields[0] {
+			case "dkim=pass":
+				if alignedDomain(fromDomain, props["header.d"]) || alignedDomain(fromDomain, domainOf(props["header.i"])) {
+					return true
+				}
+			case "spf=pass":
+				if alignedDomain(fromDomain, domainOf(props["smtp.mailfrom"])) {
+					return true
+				}
+			case "dmarc=pass":
+				if alignedDomain(fromDomain, props["header.from"]) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// alignedDomain reports whether an authenticated domain matches the From domain, or is a
+// parent domain of it
+func alignedDomain(fromDomain string, domain string) bool {
+	return domain != "" && (fromDomain == domain || strings.HasSuffix(fromDomain, "."+domain))
+}
+
+// domainOf returns the domain of an address, or the value itself when it is a domain
+func domainOf(value string) string {
+	if at := strings.LastIndex(value, "@"); at >= 0 {
+		return value[at+1:]
+	}
+	return value
+}
@@ -0,0 +1,39 @@
+package mailin
+
+import "testing"
+
+func TestSenderAuthenticated(t *testing.T) {
+	cfg := Config{AuthServID: "mx.example.com"}
+
+	tests := []struct {
+		name    string
+		from    string
+		results []string
+		want    bool
+	}{
+		{"dkim pass", "ann@corp.com", []string{"mx.example.com; dkim=pass header.d=corp.com header.s=s1"}, true},
+		{"dkim pass for a parent domain", "ann@eu.corp.com", []string{"mx.example.com; dkim=pass header.d=corp.com"}, true},
+		{"dkim pass by identity", "ann@corp.com", []string{"mx.example.com; dkim=pass header.i=@corp.com"}, true},
+		{"dkim pass for another domain", "ann@corp.com", []string{"mx.example.com; dkim=pass header.d=evil.com"}, false},
+		{"dkim pass for a lookalike domain", "ann@evilcorp.com", []string{"mx.example.com; dkim=pass header.d=corp.com"}, false},
+		{"dkim fail", "ann@corp.com", []string{"mx.example.com; dkim=fail header.d=corp.com"}, false},
+		{"spf pass", "ann@corp.com", []string{"mx.example.com; spf=pass smtp.mailfrom=bounce@corp.com"}, true},
+		{"spf pass for another envelope", "ann@corp.com", []string{"mx.example.com; spf=pass smtp.mailfrom=x@evil.com"}, false},
+		{"dmarc pass", "ann@corp.com", []string{"mx.example.com 1; spf=none; dmarc=pass (p=reject) header.from=corp.com"}, true},
+		{"case and comments", "ann@corp.com", []string{"MX.Example.COM (version 2); DKIM=Pass (good signature) header.d=Corp.com"}, true},
+		{"another server", "ann@corp.com", []string{"mx.evil.com; dkim=pass header.d=corp.com"}, false},
+		{"result in a comment", "ann@corp.com", []string{"mx.example.com; dkim=fail (dkim=pass header.d=corp.com) header.d=corp.com"}, false},
+		{"no results", "ann@corp.com", nil, false},
+		{"several headers", "ann@corp.com", []string{"mx.evil.com; dkim=pass header.d=corp.com", "mx.example.com; dkim=pass header.d=corp.com"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.SenderAuthenticated(&Parsed{From: tt.from, AuthResults: tt.results}); got != tt.want {
+			t.Errorf("%s: SenderAuthenticated = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if (Config{}).SenderAuthenticated(&Parsed{From: "ann@corp.com", AuthResults: []string{"; dkim=pass header.d=corp.com"}}) {
+		t.Errorf("without an authserv-id, no sender is authenticated")
+	}
+}
@@ -0,0 +1,137 @@
+package mailin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config controls where inbound mail is read from and who may send it
+type Config struct {
+	// Address is the mailbox tasks are sent to, e.g. tasks@example.com. Replies to
+	// notifications go to its plus-addressed variant, tasks+<reply token>@example.com.
+	Address string
+	// Dir is the drop directory polled for .eml files (empty disables it)
+	Dir string
+	// PollInterval between two scans of the drop directory
+	PollInterval time.Duration
+	// SMTPAddr is the address of the local SMTP listener, e.g. 127.0.0.1:2525 (empty disables it)
+	SMTPAddr string
+	// Allowlist lists the senders accepted without a user account, as addresses or @domain entries.
+	// Senders mapped to a user are always accepted once authenticated.
+	Allowlist []string
+	// AuthServID is the authserv-id of the upstream mail server whose Authentication-Results
+	// headers are trusted, e.g. mx.example.com. Senders are only mapped to user accounts when
+	// it authenticated them; without it, every sender is treated as unknown.
+	AuthServID string
+	// DefaultUserID creates the tasks of allowlisted senders who have no user account (0 refuses them)
+	DefaultUserID uint
+	// TaskTypeID is the type of tasks created from mail
+	TaskTypeID uint
+	// Secret signs the reply tokens
+	Secret string
+}
+
+// LoadConfig reads the inbound mail configuration from the environment:
+//
+//	INBOUND_MAIL_ADDRESS          (required, e.g. tasks@example.com)
+//	INBOUND_MAIL_SECRET           (required, signs reply tokens)
+//	INBOUND_MAIL_DIR              (drop directory, default disabled)
+//	INBOUND_MAIL_POLL_SECONDS     (default 30)
+//	INBOUND_MAIL_SMTP_ADDR        (SMTP listener, default disabled)
+//	INBOUND_MAIL_ALLOWLIST        (comma separated addresses and @domains)
+//	INBOUND_MAIL_AUTHSERV_ID      (server whose Authentication-Results are trusted)
+//	INBOUND_MAIL_DEFAULT_USER_ID  (default 0, unknown senders are refused)
+//	INBOUND_MAIL_TASK_TYPE_ID     (required to create tasks)
+func LoadConfig() Config {
+	cfg := Config{
+		Address:       strings.ToLower(strings.TrimSpace(os.Getenv("INBOUND_MAIL_ADDRESS"))),
+		Secret:        os.Getenv("INBOUND_MAIL_SECRET"),
+		Dir:           os.Getenv("INBOUND_MAIL_DIR"),
+		PollInterval:  time.Duration(envInt("INBOUND_MAIL_POLL_SECONDS", 30)) * time.Second,
+		SMTPAddr:      os.Getenv("INBOUND_MAIL_SMTP_ADDR"),
+		AuthServID:    strings.TrimSpace(os.Getenv("INBOUND_MAIL_AUTHSERV_ID")),
+		DefaultUserID: uint(envInt("INBOUND_MAIL_DEFAULT_USER_ID", 0)),
+		TaskTypeID:    uint(envInt("INBOUND_MAIL_TASK_TYPE_ID", 0)),
+	}
+	for _, entry := range strings.Split(os.Getenv("INBOUND_MAIL_ALLOWLIST"), ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			cfg.Allowlist = append(cfg.Allowlist, entry)
+		}
+	}
+	return cfg
+}
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("mailin: ignoring invalid %s=%q", key, value)
+		return fallback
+	}
+	return n
+}
+
+// Enabled reports whether inbound mail is configured
+func (c Config) Enabled() bool {
+	return strings.Contains(c.Address, "@") && c.Secret != ""
+}
+
+// Allowed reports whether a sender is on the allowlist
+func (c Config) Allowed(sender string) bool {
+	sender = strings.ToLower(sender)
+	for _, entry := range c.Allowlist {
+		if entry == sender || (strings.HasPrefix(entry, "@") && strings.HasSuffix(sender, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// replySignature signs a task and user pair
+func (c Config) replySignature(taskID uint, userID uint) string {
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	fmt.Fprintf(mac, "%d:%d", taskID, userID)
+	return hex.EncodeToString(mac.Sum(nil))[:20]
+}
+
+// ReplyAddress is the address a user replies to in order to comment on a task. The token
+// is signed, so it cannot be forged for another task or user.
+func (c Config) ReplyAddress(taskID uint, userID uint) string {
+	at := strings.LastIndex(c.Address, "@")
+	if at < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s+t%du%d.%s%s", c.Address[:at], taskID, userID, c.replySignature(taskID, userID), c.Address[at:])
+}
+
+// ParseReplyAddress extracts the task and user of a reply address. It fails for other
+// addresses and for tokens with a wrong signature.
+func (c Config) ParseReplyAddress(address string) (uint, uint, bool) {
+	address = strings.ToLower(address)
+	at := strings.LastIndex(c.Address, "@")
+	plus := strings.Index(address, "+")
+	if at < 0 || plus < 0 || address[:plus] != c.Address[:at] || !strings.HasSuffix(address, c.Address[at:]) {
+		return 0, 0, false
+	}
+	token := strings.TrimSuffix(address[plus+1:], c.Address[at:])
+
+	var taskID, userID uint
+	var signature string
+	if n, _ := fmt.Sscanf(strings.Replace(token, ".", " ", 1), "t%du%d %s", &taskID, &userID, &signature); n != 3 {
+		return 0, 0, false
+	}
+	if !hmac.Equal([]byte(signature), []byte(c.replySignature(taskID, userID))) {
+		return 0, 0, false
+	}
+	return taskID, userID, true
+}
@@ -0,0 +1,95 @@
+package mailin
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Files of the drop directory move through subdirectories: a message is claimed by moving
+// it to processing/, then ends in processed/ or, when rejected, in failed/ next to a .err
+// file with the reason. Temporary failures put it back to be tried on the next scan.
+const (
+	processingDir = "processing"
+	processedDir  = "processed"
+	failedDir     = "failed"
+)
+
+func watchDropDir(db *gorm.DB, cfg Config, handle Handler) {
+	for _, sub := range []string{processingDir, processedDir, failedDir} {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, sub), 0755); err != nil {
+			log.Printf("mailin: drop directory disabled: %v", err)
+			return
+		}
+	}
+
+	// Messages left in processing/ by a crash are tried again; the handler skips the
+	// ones that were already stored
+	if leftovers, err := filepath.Glob(filepath.Join(cfg.Dir, processingDir, "*.eml")); err == nil {
+		for _, path := range leftovers {
+			os.Rename(path, filepath.Join(cfg.Dir, filepath.Base(path)))
+		}
+	}
+
+	interval := cfg.PollInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	for {
+		scanDropDir(db, cfg, handle)
+		time.Sleep(interval)
+	}
+}
+
+func scanDropDir(db *gorm.DB, cfg Config, handle Handler) {
+	paths, err := filepath.Glob(filepath.Join(cfg.Dir, "*.eml"))
+	if err != nil {
+		log.Printf("mailin: failed to scan drop directory: %v", err)
+		return
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		claimed := filepath.Join(cfg.Dir, processingDir, name)
+		if err := os.Rename(path, claimed); err != nil {
+			// Claimed by another process in the meantime
+			continue
+		}
+
+		err := processDropFile(db, cfg, handle, claimed)
+		var reject *RejectError
+		switch {
+		case err == nil:
+			os.Rename(claimed, filepath.Join(cfg.Dir, processedDir, name))
+		case errors.As(err, &reject):
+			log.Printf("mailin: rejected %s: %s", name, reject.Reason)
+			os.Rename(claimed, filepath.Join(cfg.Dir, failedDir, name))
+			os.WriteFile(filepath.Join(cfg.Dir, failedDir, strings.TrimSuffix(name, ".eml")+".err"), []byte(reject.Reason+"\n"), 0644)
+		default:
+			log.Printf("mailin: failed to process %s, will retry: %v", name, err)
+			os.Rename(claimed, path)
+		}
+	}
+}
+
+func processDropFile(db *gorm.DB, cfg Config, handle Handler, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(f, MaxMessageBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(raw) > MaxMessageBytes {
+		return Reject("message exceeds %d bytes", MaxMessageBytes)
+	}
+	return handle(db, cfg, Message{Raw: raw})
+}
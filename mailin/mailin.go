@@ -0,0 +1,56 @@
+package mailin
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// MaxMessageBytes is the largest message accepted, attachments included
+const MaxMessageBytes = 25 << 20
+
+// Message is a raw RFC 5322 message with the recipients it was delivered to
+type Message struct {
+	Raw []byte
+	// Recipients are the envelope recipients when known (SMTP). Handlers also look at the
+	// To and Cc headers, which is all a drop directory provides.
+	Recipients []string
+}
+
+// Handler processes one message. Returning a RejectError refuses the message for good;
+// any other error is temporary and the message is tried again.
+type Handler func(db *gorm.DB, cfg Config, msg Message) error
+
+// RejectError refuses a message that will never be accepted, e.g. from an unknown sender
+type RejectError struct {
+	Reason string
+}
+
+func (e *RejectError) Error() string { return e.Reason }
+
+// Reject returns a RejectError
+func Reject(format string, args ...interface{}) error {
+	return &RejectError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Start runs the configured sources, the drop directory and the SMTP listener, in the
+// background until the process exits. Nothing runs when inbound mail is not configured.
+func Start(db *gorm.DB, cfg Config, handle Handler) {
+	if !cfg.Enabled() {
+		return
+	}
+	if cfg.AuthServID == "" {
+		log.Printf("mailin: INBOUND_MAIL_AUTHSERV_ID is not set, so no sender is mapped to a user account")
+	}
+	if cfg.Dir != "" {
+		go watchDropDir(db, cfg, handle)
+	}
+	if cfg.SMTPAddr != "" {
+		go func() {
+			if err := listenSMTP(db, cfg, handle); err != nil {
+				log.Printf("mailin: SMTP listener stopped: %v", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,241 @@
+package mailin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// Parsed is the part of a message the processor needs
+type Parsed struct {
+	MessageID string
+	From      string // lowercase address
+	Subject   string
+	// Recipients are the addresses of the To, Cc, Delivered-To and X-Original-To headers
+	Recipients  []string
+	Text        string // the text/plain body, or the text of the HTML body
+	Attachments []Attachment
+	// AuthResults are the Authentication-Results headers, checked by SenderAuthenticated
+	AuthResults []string
+	// AutoGenerated is set for automatic replies and bulk mail, which are never processed
+	// so that an out-of-office reply cannot start a mail loop
+	AutoGenerated bool
+
+	html string
+}
+
+// maxPartDepth bounds the nesting of multipart bodies
+const maxPartDepth = 10
+
+var headerDecoder = &mime.WordDecoder{CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+	b, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(decodeCharset(b, charset)), nil
+}}
+
+// Parse reads an RFC 5322 message
+func Parse(raw []byte) (*Parsed, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Parsed{}
+	p.MessageID = strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>")
+	if p.MessageID == "" || len(p.MessageID) > 191 {
+		// Without a usable ID, redeliveries of the same bytes are still recognised
+		sum := sha256.Sum256(raw)
+		p.MessageID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid From header: %v", err)
+	}
+	p.From = strings.ToLower(from.Address)
+
+	if subject, err := headerDecoder.DecodeHeader(msg.Header.Get("Subject")); err == nil {
+		p.Subject = strings.TrimSpace(subject)
+	} else {
+		p.Subject = strings.TrimSpace(msg.Header.Get("Subject"))
+	}
+
+	for _, key := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		for _, value := range msg.Header[key] {
+			if list, err := mail.ParseAddressList(value); err == nil {
+				for _, address := range list {
+					p.Recipients = append(p.Recipients, strings.ToLower(address.Address))
+				}
+			}
+		}
+	}
+
+	p.AuthResults = msg.Header["Authentication-Results"]
+
+	autoSubmitted := strings.ToLower(msg.Header.Get("Auto-Submitted"))
+	precedence := strings.ToLower(msg.Header.Get("Precedence"))
+	p.AutoGenerated = (autoSubmitted != "" && autoSubmitted != "no") ||
+		precedence == "bulk" || precedence == "junk" || precedence == "auto_reply" ||
+		msg.Header.Get("X-Autoreply") != "" || msg.Header.Get("X-Autorespond") != ""
+
+	if err := p.readPart(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, err
+	}
+	if p.Text == "" && p.html != "" {
+		p.Text = htmlToText(p.html)
+	}
+	p.Text = strings.TrimSpace(strings.ReplaceAll(p.Text, "\r\n", "\n"))
+	return p, nil
+}
+
+// readPart collects the body text and the attachments of a (possibly multipart) part
+func (p *Parsed) readPart(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+		if header.Get("Content-Type") != "" {
+			mediaType = "application/octet-stream"
+		}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxPartDepth {
+			return errors.New("message parts are nested too deeply")
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.readPart(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if decoded, err := headerDecoder.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+
+	switch {
+	case disposition != "attachment" && filename == "" && mediaType == "text/plain":
+		if p.Text == "" {
+			p.Text = decodeCharset(content, params["charset"])
+		}
+	case disposition != "attachment" && filename == "" && mediaType == "text/html":
+		if p.html == "" {
+			p.html = decodeCharset(content, params["charset"])
+		}
+	default:
+		if filename == "" {
+			filename = "attachment"
+			if extensions, _ := mime.ExtensionsByType(mediaType); len(extensions) > 0 {
+				filename += extensions[0]
+			}
+		}
+		p.Attachments = append(p.Attachments, Attachment{Filename: filename, ContentType: mediaType, Content: content})
+	}
+	return nil
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// The decoder skips the line breaks
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// decodeCharset converts text to UTF-8. Latin-1 and its Windows variant are converted
+// byte by byte; other charsets are kept as they are, with invalid sequences replaced.
+func decodeCharset(b []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		if !utf8.Valid(b) {
+			runes := make([]rune, len(b))
+			for i, c := range b {
+				runes[i] = rune(c)
+			}
+			return string(runes)
+		}
+	}
+	return strings.ToValidUTF8(string(b), "�")
+}
+
+var (
+	htmlHiddenPattern = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+	htmlBreakPattern  = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6])>`)
+	htmlTagPattern    = regexp.MustCompile(`<[^>]*>`)
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText turns an HTML body into plain text for messages without a text part
+func htmlToText(s string) string {
+	s = htmlHiddenPattern.ReplaceAllString(s, "")
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
+
+var replyHeaderPattern = regexp.MustCompile(`^(On|Le|Am|El) .+(wrote|écrit|schrieb|escribió):$`)
+
+// StripQuotedReply keeps the new text of a reply: everything above the quoted message,
+// the "On ... wrote:" line, a forwarded or original message marker, or the signature
+func StripQuotedReply(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") || line == "-- " ||
+			replyHeaderPattern.MatchString(trimmed) ||
+			strings.HasPrefix(trimmed, "-----Original Message-----") ||
+			strings.HasPrefix(trimmed, "________________________________") {
+			lines = lines[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,179 @@
+package mailin
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// listenSMTP accepts mail over a minimal SMTP server meant for a local relay (no TLS, no
+// authentication), so it should only listen on a private address. Recipients outside the
+// inbound mailbox are refused, so it cannot be used as an open relay.
+func listenSMTP(db *gorm.DB, cfg Config, handle Handler) error {
+	listener, err := net.Listen("tcp", cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	log.Printf("mailin: SMTP listener on %s", cfg.SMTPAddr)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go serveSMTP(db, cfg, handle, conn)
+	}
+}
+
+// acceptsRecipient reports whether an address is the inbound mailbox or one of its
+// plus-addressed variants
+func (c Config) acceptsRecipient(address string) bool {
+	address = strings.ToLower(address)
+	at := strings.LastIndex(c.Address, "@")
+	if address == c.Address {
+		return true
+	}
+	plus := strings.Index(address, "+")
+	return plus >= 0 && address[:plus] == c.Address[:at] && strings.HasSuffix(address, c.Address[at:])
+}
+
+func serveSMTP(db *gorm.DB, cfg Config, handle Handler, conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(code int, message string) bool {
+		conn.SetWriteDeadline(time.Now().Add(time.Minute))
+		return text.PrintfLine("%d %s", code, message) == nil
+	}
+
+	hostname := "localhost"
+	if at := strings.LastIndex(cfg.Address, "@"); at >= 0 {
+		hostname = cfg.Address[at+1:]
+	}
+	if !reply(220, hostname+" ESMTP ready") {
+		return
+	}
+
+	// The sender is not used: the From header is what identifies the author
+	var started bool
+	var recipients []string
+	reset := func() {
+		started = false
+		recipients = nil
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch strings.ToUpper(verb) {
+		case "HELO", "EHLO":
+			reset()
+			if !reply(250, hostname) {
+				return
+			}
+		case "MAIL":
+			if _, ok := smtpPath(arg, "FROM:"); !ok {
+				reply(501, "Syntax: MAIL FROM:<address>")
+				continue
+			}
+			reset()
+			started = true
+			reply(250, "OK")
+		case "RCPT":
+			address, ok := smtpPath(arg, "TO:")
+			switch {
+			case !ok:
+				reply(501, "Syntax: RCPT TO:<address>")
+			case !started:
+				reply(503, "MAIL first")
+			case !cfg.acceptsRecipient(address):
+				reply(550, "No such mailbox")
+			default:
+				recipients = append(recipients, address)
+				reply(250, "OK")
+			}
+		case "DATA":
+			if len(recipients) == 0 {
+				reply(503, "RCPT first")
+				continue
+			}
+			if !reply(354, "End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			body := text.DotReader()
+			raw, err := io.ReadAll(io.LimitReader(body, MaxMessageBytes+1))
+			if err != nil {
+				return
+			}
+			if len(raw) > MaxMessageBytes {
+				// Read up to the final dot, or the rest of the message would be taken as commands
+				if _, err := io.Copy(io.Discard, body); err != nil {
+					return
+				}
+				reply(552, "Message too large")
+				reset()
+				continue
+			}
+			reply(smtpResult(handle(db, cfg, Message{Raw: raw, Recipients: recipients})))
+			reset()
+		case "RSET":
+			reset()
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			reply(502, "Command not implemented")
+		}
+	}
+}
+
+// smtpResult maps the outcome of a handler to an SMTP reply
+func smtpResult(err error) (int, string) {
+	var reject *RejectError
+	switch {
+	case err == nil:
+		return 250, "OK"
+	case errors.As(err, &reject):
+		return 550, reject.Reason
+	default:
+		log.Printf("mailin: failed to process message: %v", err)
+		return 451, "Temporary failure, try again later"
+	}
+}
+
+// smtpPath parses the address of "FROM:<address> [params]" or "TO:<address>"
+func smtpPath(arg string, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if i := strings.IndexByte(path, ' '); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "<"), ">")
+	if path == "" {
+		// The null reverse path of bounces
+		return "", prefix == "FROM:"
+	}
+	address, err := mail.ParseAddress("<" + path + ">")
+	if err != nil {
+		return "", false
+	}
+	return strings.ToLower(address.Address), true
+}
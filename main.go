@@ -6,28 +6,37 @@ import (
 	"github.com/gin-contrib/cors" // New import
 	"github.com/gin-gonic/gin"
 
+	"taskmanager/controllers"
 	"taskmanager/database"
+	"taskmanager/mailin"
 	"taskmanager/routes"
+	"taskmanager/scheduler"
 )
 
 func main() {
 	database.ConnectDatabase()
 
+	// Imports run in the server process, so those of a previous run did not finish
+	controllers.FailInterruptedImports(database.DB)
+
+	// Due-date reminders and overdue escalation
+	scheduler.Start(database.DB, scheduler.LoadConfig())
+
+	// Tasks and comments from inbound email
+	mailin.Start(database.DB, mailin.LoadConfig(), controllers.ProcessInboundMail)
+
 	r := gin.Default()
 
 	// CORS Configuration
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // Frontend origin
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Serve static files from the "uploads" directory
-	r.Static("/uploads", "./uploads")
-
 	routes.SetupRoutes(r)
 	r.Run(":8080")
 }
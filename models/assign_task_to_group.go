@@ -1,9 +1,12 @@
 package models
 
+import "gorm.io/gorm"
+
 // AssignTaskToGroup represents the assignment of a task to a group
 type AssignTaskToGroup struct {
 	ID      uint `gorm:"primaryKey"`
 	GroupID uint `gorm:"not null"` // FK to groups.id
 	TaskID  uint `gorm:"not null"` // FK to tasks.id
 	Group   Group // Belongs to Group
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
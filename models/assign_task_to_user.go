@@ -1,9 +1,28 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Assignment states of a user assignment
+const (
+	AssignmentPending  = "pending"
+	AssignmentAccepted = "accepted"
+	AssignmentDeclined = "declined"
+)
+
 // AssignTaskToUser represents the assignment of a task to a user
 type AssignTaskToUser struct {
-	ID     uint `gorm:"primaryKey"`
-	UserID uint `gorm:"not null"` // FK to users.id
-	TaskID uint `gorm:"not null"` // FK to tasks.id
-	User   User // Belongs to User
-}
\ No newline at end of file
+	ID            uint           `gorm:"primaryKey"`
+	UserID        uint           `gorm:"not null"` // FK to users.id
+	TaskID        uint           `gorm:"not null"` // FK to tasks.id
+	User          User           // Belongs to User
+	Task          *Task          `gorm:"foreignKey:TaskID" json:"Task,omitempty"`
+	Status        string         `gorm:"type:enum('pending','accepted','declined');default:'pending';not null"`
+	DeclineReason string         `gorm:"type:text"`
+	RespondedAt   *time.Time     `gorm:"type:timestamp;null"`
+	CreatedAt     time.Time      `gorm:"type:timestamp;default:CURRENT_TIMESTAMP;autoCreateTime"`
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarFeed is a secret iCalendar feed URL of a user. Revoking a feed soft-deletes it.
+// Scope is "user" (the user's assigned and followed tasks), "group" or "task_type".
+// Kind is "todo" (VTODO items) or "event" (all-day VEVENT items).
+type CalendarFeed struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	UserID     uint           `gorm:"not null;index" json:"user_id"`                  // FK to users.id
+	Token      string         `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // secret part of the feed URL
+	Scope      string         `gorm:"type:enum('user','group','task_type');not null;default:'user'" json:"scope"`
+	Kind       string         `gorm:"type:enum('todo','event');not null;default:'todo'" json:"kind"`
+	GroupID    *uint          `json:"group_id,omitempty"`     // FK to groups.id, for group feeds
+	TaskTypeID *uint          `json:"task_type_id,omitempty"` // FK to task_types.id, for task type feeds
+	CreatedAt  time.Time      `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// ContentHash is the hash of the document last served and ContentChangedAt when it
+	// changed, so the Last-Modified date also moves when tasks leave the feed
+	ContentHash      string     `gorm:"type:varchar(40)" json:"-"`
+	ContentChangedAt *time.Time `gorm:"type:timestamp;null" json:"-"`
+}
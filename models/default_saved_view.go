@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// DefaultSavedView records the view a user opens by default, one of their own or a shared one
+type DefaultSavedView struct {
+	UserID      uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"` // FK to users.id
+	SavedViewID uint      `gorm:"not null;index" json:"saved_view_id"`           // FK to saved_views.id
+	UpdatedAt   time.Time `gorm:"type:timestamp;autoUpdateTime" json:"updated_at"`
+}
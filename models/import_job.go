@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// States of an import job
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportRowResult is the outcome of one row of an import file
+type ImportRowResult struct {
+	Row         int      `json:"row"` // 1-based, not counting the CSV header
+	ExternalRef string   `json:"external_ref,omitempty"`
+	Status      string   `json:"status"` // "valid" (dry run), "created", "skipped" or "failed"
+	TaskID      *uint    `json:"task_id,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// ImportJob is a task import running in the background
+type ImportJob struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"` // FK to users.id, creator of the imported tasks
+	Format        string     `gorm:"type:enum('csv','json');not null" json:"format"`
+	FileName      string     `gorm:"type:varchar(255)" json:"file_name"`
+	Status        string     `gorm:"type:enum('queued','running','completed','failed');not null;default:'queued'" json:"status"`
+	TotalRows     int        `gorm:"not null;default:0" json:"total_rows"`
+	ProcessedRows int        `gorm:"not null;default:0" json:"processed_rows"`
+	CreatedRows   int        `gorm:"not null;default:0" json:"created_rows"`
+	SkippedRows   int        `gorm:"not null;default:0" json:"skipped_rows"` // already imported, by external reference
+	FailedRows    int        `gorm:"not null;default:0" json:"failed_rows"`
+	Error         string     `gorm:"type:text" json:"error,omitempty"` // why the whole job failed
+	Report        string     `gorm:"type:longtext" json:"-"`           // JSON array of ImportRowResult
+	CreatedAt     time.Time  `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+	StartedAt     *time.Time `gorm:"type:timestamp;null" json:"started_at"`
+	FinishedAt    *time.Time `gorm:"type:timestamp;null" json:"finished_at"`
+
+	Results []ImportRowResult `gorm:"-" json:"results,omitempty"`
+}
+
+// Progress is the share of processed rows, in percent
+func (j ImportJob) Progress() int {
+	if j.TotalRows == 0 {
+		return 100
+	}
+	return j.ProcessedRows * 100 / j.TotalRows
+}
+
+// MarshalJSON adds the progress to the JSON representation
+func (j ImportJob) MarshalJSON() ([]byte, error) {
+	type importJob ImportJob
+	return json.Marshal(struct {
+		importJob
+		Progress int `json:"progress"`
+	}{importJob(j), j.Progress()})
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Outcomes of an inbound message
+const (
+	InboundMailTask     = "task"     // created a task
+	InboundMailComment  = "comment"  // commented on a task
+	InboundMailRejected = "rejected" // refused, see Reason
+)
+
+// InboundMail logs the messages handled by the inbound mail processor. The unique message
+// ID makes redelivered messages no-ops.
+type InboundMail struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	MessageID   string    `gorm:"type:varchar(191);not null;uniqueIndex" json:"message_id"`
+	Sender      string    `gorm:"type:varchar(255);not null" json:"sender"`
+	Subject     string    `gorm:"type:varchar(255)" json:"subject"`
+	UserID      *uint     `json:"user_id"` // FK to users.id, author of the task or comment
+	Status      string    `gorm:"type:enum('task','comment','rejected');not null" json:"status"`
+	TaskID      *uint     `json:"task_id"`                                // FK to tasks.id
+	CommentID   *uint     `json:"comment_id"`                             // FK to task_comment_logs.id
+	Attachments string    `gorm:"type:text" json:"attachments,omitempty"` // paths of the files saved from the message, one per line
+	Reason      string    `gorm:"type:varchar(255)" json:"reason,omitempty"`
+	CreatedAt   time.Time `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MailSender maps an email address to the user whose name tasks and comments sent from it
+// are created under. Mapped senders are always accepted by the inbound mail processor.
+type MailSender struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Address   string    `gorm:"type:varchar(191);not null;uniqueIndex" json:"address"` // lowercase
+	UserID    uint      `gorm:"not null;index" json:"user_id"`                         // FK to users.id
+	User      *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CreatedBy uint      `gorm:"not null" json:"created_by"` // FK to users.id
+	CreatedAt time.Time `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+}
@@ -6,10 +6,11 @@ type Notification struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	UserID    uint      `json:"user_id"`
 	User      User      `json:"user"`
-	TaskID    uint      `json:"task_id"`
+	TaskID    *uint     `json:"task_id"` // nil for a summary of several tasks
 	Task      Task      `json:"task"`
 	Type      string    `json:"type"`
 	Message   string    `json:"message"`
 	IsRead    bool      `json:"is_read" gorm:"default:false"`
 	CreatedAt time.Time `json:"created_at"`
+	ReplyTo   string    `gorm:"-" json:"reply_to,omitempty"` // address to comment on the task by email
 }
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SavedView is a named My Tasks filter with its display settings. A view may be shared
+// with a group, whose members can then use it but not change it.
+type SavedView struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	UserID        uint           `gorm:"not null;index" json:"user_id"` // FK to users.id of the owner
+	Name          string         `gorm:"type:varchar(100);not null" json:"name"`
+	Filter        string         `gorm:"type:text" json:"-"`                               // GetMyTasksFilterInput as sent by the client
+	Sort          string         `gorm:"type:varchar(50);not null;default:''" json:"sort"` // column, prefixed with "-" for descending
+	Columns       string         `gorm:"type:text" json:"-"`                               // JSON array of column names
+	GroupBy       string         `gorm:"type:varchar(30);not null;default:''" json:"group_by"`
+	SharedGroupID *uint          `gorm:"index" json:"shared_group_id"` // FK to groups.id
+	CreatedAt     time.Time      `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"type:timestamp;autoUpdateTime" json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	FilterData json.RawMessage `gorm:"-" json:"filter"`
+	ColumnList []string        `gorm:"-" json:"columns"`
+
+	// IsDefault is computed per user
+	IsDefault bool `gorm:"-" json:"is_default"`
+}
+
+// BeforeSave stores FilterData and ColumnList as JSON
+func (v *SavedView) BeforeSave(tx *gorm.DB) error {
+	v.Filter = "{}"
+	if len(v.FilterData) > 0 {
+		v.Filter = string(v.FilterData)
+	}
+	if v.ColumnList == nil {
+		v.Columns = "[]"
+		return nil
+	}
+	columns, err := json.Marshal(v.ColumnList)
+	if err != nil {
+		return err
+	}
+	v.Columns = string(columns)
+	return nil
+}
+
+// AfterFind fills FilterData and ColumnList from their JSON columns
+func (v *SavedView) AfterFind(tx *gorm.DB) error {
+	v.FilterData = json.RawMessage("{}")
+	if v.Filter != "" {
+		v.FilterData = json.RawMessage(v.Filter)
+	}
+	v.ColumnList = []string{}
+	if v.Columns == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(v.Columns), &v.ColumnList)
+}
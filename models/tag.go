@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Tag represents a cross-cutting label that can be attached to many tasks
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Label     string    `gorm:"type:varchar(100);not null;unique" json:"label"`
+	Color     string    `gorm:"type:varchar(7);default:'#6b7280'" json:"color"`
+	CreatedBy uint      `gorm:"not null" json:"created_by"` // FK to users.id
+	CreatedAt time.Time `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"type:timestamp;autoUpdateTime" json:"updated_at"`
+}
+
+type TagResponse struct {
+	ID         uint   `json:"id"`
+	Label      string `json:"label"`
+	Color      string `json:"color"`
+	CreatedBy  uint   `json:"created_by"`
+	UsageCount int64  `json:"usage_count"`
+}
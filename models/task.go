@@ -1,25 +1,75 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Task represents the task model
 type Task struct {
-	ID             uint              `gorm:"primaryKey" json:"ID"`
-	Label          string            `gorm:"not null" json:"Label"`
-	TaskTypeID     uint              `gorm:"not null" json:"TaskTypeID"`
-	Priority       string            `gorm:"type:enum('Normal', 'Medium', 'High', 'Escalation');default:'Normal'" json:"Priority"`
-	StartDate      time.Time         `gorm:"type:date" json:"StartDate"`
-	DueDate        *time.Time        `gorm:"type:date" json:"DueDate"`
-	Description    string            `gorm:"type:longtext" json:"Description"`
-	Attachment     string            `gorm:"type:varchar(255);nullable" json:"Attachment"`
-	Status         string            `gorm:"type:enum('Pending','In Progress','In Review','Completed');default:'Pending'" json:"Status"`
-	CreatedBy      uint              `gorm:"not null" json:"CreatedBy"`
-	Creator        User              `gorm:"foreignKey:CreatedBy" json:"Creator"`
-	CreatedAt      time.Time         `gorm:"type:timestamp;autoCreateTime" json:"CreatedAt"`
-	UpdatedAt      time.Time         `gorm:"type:timestamp;autoUpdateTime" json:"UpdatedAt"`
-	AssignedUsers  []AssignTaskToUser `gorm:"foreignKey:TaskID" json:"AssignedUsers"`
-	AssignedGroups []AssignTaskToGroup `gorm:"foreignKey:TaskID" json:"AssignedGroups"`
-	FollowupUsers  []TaskFollowupUser `gorm:"foreignKey:TaskID" json:"FollowupUsers"`
-	FollowupGroups []TaskFollowupGroup `gorm:"foreignKey:TaskID" json:"FollowupGroups"`
-	Comments       []TaskCommentLog  `gorm:"foreignKey:TaskID" json:"Comments"`
-}
\ No newline at end of file
+	ID                uint                `gorm:"primaryKey" json:"ID"`
+	Label             string              `gorm:"not null" json:"Label"`
+	TaskTypeID        uint                `gorm:"not null" json:"TaskTypeID"`
+	Priority          string              `gorm:"type:enum('Normal', 'Medium', 'High', 'Escalation');default:'Normal'" json:"Priority"`
+	StartDate         time.Time           `gorm:"type:date" json:"StartDate"`
+	DueDate           *time.Time          `gorm:"type:date" json:"DueDate"`
+	Description       string              `gorm:"type:longtext" json:"Description"`
+	Attachment        string              `gorm:"type:varchar(255);nullable" json:"Attachment"`
+	Status            string              `gorm:"type:enum('Pending','In Progress','In Review','Completed');default:'Pending'" json:"Status"`
+	Confidentiality   string              `gorm:"type:enum('normal','restricted','private');default:'normal';not null" json:"Confidentiality"`
+	Progress          uint                `gorm:"type:tinyint unsigned;not null;default:0;comment:percent" json:"Progress"` // latest progress reported by an assignee
+	OriginalEstimate  *uint               `gorm:"comment:minutes" json:"OriginalEstimate"`
+	RemainingEstimate *uint               `gorm:"comment:minutes" json:"RemainingEstimate"`
+	OverdueSince      *time.Time          `gorm:"type:timestamp;null" json:"OverdueSince"`
+	LastActivityAt    *time.Time          `gorm:"type:timestamp;null" json:"LastActivityAt"`                                                 // last comment or status change
+	ParentID          *uint               `gorm:"index" json:"ParentID"`                                                                     // FK to tasks.id, set on subtasks
+	ClonedFromID      *uint               `gorm:"index" json:"ClonedFromID"`                                                                 // FK to tasks.id of the clone source
+	ExternalRef       *string             `gorm:"type:varchar(191);uniqueIndex:idx_task_creator_external_ref,priority:2" json:"ExternalRef"` // ID in the system the task was imported from, unique per creator
+	Version           uint                `gorm:"not null;default:1" json:"Version"`                                                         // incremented on every update, used for ETags
+	BoardRank         string              `gorm:"type:varchar(64);not null;default:'';index" json:"BoardRank"`                               // manual order within a board column, empty until moved
+	CreatedBy         uint                `gorm:"not null;uniqueIndex:idx_task_creator_external_ref,priority:1" json:"CreatedBy"`
+	Creator           User                `gorm:"foreignKey:CreatedBy" json:"Creator"`
+	CreatedAt         time.Time           `gorm:"type:timestamp;autoCreateTime" json:"CreatedAt"`
+	UpdatedAt         time.Time           `gorm:"type:timestamp;autoUpdateTime" json:"UpdatedAt"`
+	DeletedAt         gorm.DeletedAt      `gorm:"index" json:"DeletedAt,omitempty"`
+	AssignedUsers     []AssignTaskToUser  `gorm:"foreignKey:TaskID" json:"AssignedUsers"`
+	AssignedGroups    []AssignTaskToGroup `gorm:"foreignKey:TaskID" json:"AssignedGroups"`
+	FollowupUsers     []TaskFollowupUser  `gorm:"foreignKey:TaskID" json:"FollowupUsers"`
+	FollowupGroups    []TaskFollowupGroup `gorm:"foreignKey:TaskID" json:"FollowupGroups"`
+	Comments          []TaskCommentLog    `gorm:"foreignKey:TaskID" json:"Comments"`
+	Escalations       []TaskEscalationLog `gorm:"foreignKey:TaskID" json:"Escalations,omitempty"`
+	Subtasks          []Task              `gorm:"foreignKey:ParentID" json:"Subtasks,omitempty"`
+	Tags              []Tag               `gorm:"many2many:task_tags" json:"Tags"`
+	FieldValues       []TaskFieldValue    `gorm:"foreignKey:TaskID" json:"CustomFields"`
+
+	// ReadState is computed per user: "unseen", "updated" (activity since the last view) or "seen"
+	ReadState string `gorm:"-" json:"ReadState,omitempty"`
+	// DescriptionHTML is the sanitized rendering of the Markdown description, computed per
+	// user since task references only link to tasks the user can see
+	DescriptionHTML string `gorm:"-" json:"DescriptionHTML"`
+}
+
+// Confidentiality levels of a task. Restricted tasks are only visible to the creator, the
+// users named on the task (assignees and follow-ups, groups do not count) and super admins.
+// Private tasks are only visible to the creator and the named users.
+const (
+	ConfidentialityNormal     = "normal"
+	ConfidentialityRestricted = "restricted"
+	ConfidentialityPrivate    = "private"
+)
+
+// IsConfidential reports whether the task is restricted or private
+func (t Task) IsConfidential() bool {
+	return t.Confidentiality == ConfidentialityRestricted || t.Confidentiality == ConfidentialityPrivate
+}
+
+// NotificationLabel is how notification messages refer to the task. Confidential tasks are
+// referred to by ID only, so their label does not leak through notifications.
+func (t Task) NotificationLabel() string {
+	if t.IsConfidential() {
+		return fmt.Sprintf("#%d (confidential)", t.ID)
+	}
+	return t.Label
+}
@@ -1,14 +1,31 @@
 package models
 
-import "time"
+import (
+	"time"
 
-// TaskCommentLog logs comments on tasks
+	"gorm.io/gorm"
+)
+
+// TaskCommentLog logs comments on tasks. Comment holds the Markdown source.
+// Deleting a comment soft-deletes it; it is still listed, as a placeholder without its text.
 type TaskCommentLog struct {
-	ID        uint      `gorm:"primaryKey"`
-	TaskID    uint      `gorm:"not null"` // FK to tasks.id
-	UserID    uint      `gorm:"not null"` // FK to users.id
-	Comment   string    `gorm:"type:text"`
-	CreatedAt time.Time `gorm:"type:timestamp;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"type:timestamp;autoUpdateTime"`
-	User      User      `gorm:"foreignKey:UserID"`
+	ID        uint             `gorm:"primaryKey"`
+	TaskID    uint             `gorm:"not null"` // FK to tasks.id
+	UserID    uint             `gorm:"not null"` // FK to users.id
+	Comment   string           `gorm:"type:text"`
+	ParentID  *uint            `gorm:"index"`               // FK to task_comment_logs.id, set on replies
+	EditedAt  *time.Time       `gorm:"type:timestamp;null"` // last edit by the author
+	RemovedBy *uint            // FK to users.id, the author or a moderator who deleted the comment
+	CreatedAt time.Time        `gorm:"type:timestamp;autoCreateTime"`
+	UpdatedAt time.Time        `gorm:"type:timestamp;autoUpdateTime"`
+	DeletedAt gorm.DeletedAt   `gorm:"index"`
+	User      User             `gorm:"foreignKey:UserID"`
+	Replies   []TaskCommentLog `gorm:"foreignKey:ParentID"`
+
+	// CommentHTML is the sanitized rendering of the Markdown comment, computed per user
+	CommentHTML string `gorm:"-"`
+	// Placeholder replaces the text of a deleted comment
+	Placeholder string `gorm:"-" json:",omitempty"`
+	// Reactions are computed per user
+	Reactions []CommentReactionSummary `gorm:"-"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// TaskCommentReaction is an emoji reaction of a user to a comment
+type TaskCommentReaction struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CommentID uint      `gorm:"not null;uniqueIndex:idx_comment_reaction" json:"comment_id"`                                 // FK to task_comment_logs.id
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_comment_reaction" json:"user_id"`                                    // FK to users.id
+	Emoji     string    `gorm:"type:varchar(32) COLLATE utf8mb4_bin;not null;uniqueIndex:idx_comment_reaction" json:"emoji"` // binary, so that different emoji never compare equal
+	CreatedAt time.Time `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+}
+
+// CommentReactionSummary counts the reactions to a comment with one emoji
+type CommentReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"` // whether the reading user is one of them
+}
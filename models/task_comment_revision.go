@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TaskCommentRevision keeps the text a comment had before an edit
+type TaskCommentRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CommentID uint      `gorm:"not null;index" json:"comment_id"` // FK to task_comment_logs.id
+	Comment   string    `gorm:"type:text" json:"comment"`         // the text replaced by the edit
+	EditedBy  uint      `gorm:"not null" json:"edited_by"`        // FK to users.id
+	Editor    *User     `gorm:"foreignKey:EditedBy" json:"editor,omitempty"`
+	CreatedAt time.Time `gorm:"type:timestamp;autoCreateTime" json:"created_at"` // when the edit was made
+}
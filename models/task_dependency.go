@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskDependency is a finish-to-start dependency: the task cannot start before DependsOn is finished
+type TaskDependency struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	TaskID      uint           `gorm:"not null;index" json:"task_id"`       // FK to tasks.id of the dependent task
+	DependsOnID uint           `gorm:"not null;index" json:"depends_on_id"` // FK to tasks.id of the prerequisite
+	CreatedBy   uint           `gorm:"not null" json:"created_by"`
+	CreatedAt   time.Time      `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	DependsOn   *Task          `gorm:"foreignKey:DependsOnID" json:"depends_on,omitempty"`
+}
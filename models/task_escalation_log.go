@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskEscalationLog logs automatic priority escalations of overdue tasks and of tasks
+// nobody accepted in time
+type TaskEscalationLog struct {
+	ID           uint           `gorm:"primaryKey"`
+	TaskID       uint           `gorm:"not null;index"` // FK to tasks.id
+	FromPriority string         `gorm:"type:varchar(20)"`
+	ToPriority   string         `gorm:"type:varchar(20)"`
+	DaysOverdue  int            `gorm:"not null"`
+	Reason       string         `gorm:"type:varchar(20);not null;default:'overdue'"` // overdue or unaccepted
+	CreatedAt    time.Time      `gorm:"type:timestamp;autoCreateTime"`
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskFieldValue stores the value of a custom field on a task.
+// Multi select fields store one row per selected option.
+type TaskFieldValue struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	TaskID      uint           `gorm:"not null;index" json:"task_id"`  // FK to tasks.id
+	FieldID     uint           `gorm:"not null;index" json:"field_id"` // FK to task_type_fields.id
+	Value       string         `gorm:"type:varchar(1000)" json:"value"`
+	NumberValue *float64       `json:"-"`
+	DateValue   *time.Time     `gorm:"type:date" json:"-"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	Field       TaskTypeField  `gorm:"foreignKey:FieldID" json:"field"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskHistory logs a single field change on a task.
+// UserID is nil for changes made by the system, e.g. scheduler escalations.
+type TaskHistory struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	TaskID    uint           `gorm:"not null;index" json:"task_id"` // FK to tasks.id
+	UserID    *uint          `gorm:"index" json:"user_id"`          // FK to users.id
+	Field     string         `gorm:"type:varchar(100);not null" json:"field"`
+	OldValue  string         `gorm:"type:text" json:"old_value"`
+	NewValue  string         `gorm:"type:text" json:"new_value"`
+	CreatedAt time.Time      `gorm:"type:timestamp;autoCreateTime;index" json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	User      *User          `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TaskReminder records a reminder or escalation step already sent for a task,
+// so that the scheduler never sends the same step twice for the same due date
+type TaskReminder struct {
+	ID        uint      `gorm:"primaryKey"`
+	TaskID    uint      `gorm:"not null;uniqueIndex:idx_task_reminder_step"` // FK to tasks.id
+	Kind      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_task_reminder_step"`
+	DueDate   time.Time `gorm:"type:date;not null;uniqueIndex:idx_task_reminder_step"`
+	CreatedAt time.Time `gorm:"type:timestamp;autoCreateTime"`
+}
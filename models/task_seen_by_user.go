@@ -1,12 +1,19 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // TaskSeenByUser logs when a task is seen by a user
 type TaskSeenByUser struct {
-	ID        uint      `gorm:"primaryKey"`
-	TaskID    uint      `gorm:"not null"` // FK to tasks.id
-	UserID    uint      `gorm:"not null"` // FK to users.id
-	CreatedAt time.Time `gorm:"type:timestamp;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"type:timestamp;autoUpdateTime"`
+	ID        uint           `gorm:"primaryKey"`
+	TaskID    uint           `gorm:"not null;uniqueIndex:idx_task_seen_by_user"` // FK to tasks.id
+	UserID    uint           `gorm:"not null;uniqueIndex:idx_task_seen_by_user"` // FK to users.id
+	SeenAt    time.Time      `gorm:"type:timestamp;not null"`                    // last time the user opened the task
+	CreatedAt time.Time      `gorm:"type:timestamp;autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"type:timestamp;autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	User      User           `gorm:"foreignKey:UserID"`
 }
@@ -1,13 +1,21 @@
 package models
 
-import "time"
+import (
+	"time"
 
-// TaskStatusUpdateLog logs task status changes
+	"gorm.io/gorm"
+)
+
+// TaskStatusUpdateLog logs task status changes and progress updates of assignees
 type TaskStatusUpdateLog struct {
-	ID        uint      `gorm:"primaryKey"`
-	TaskID    uint      `gorm:"not null"` // FK to tasks.id
-	UserID    uint      `gorm:"not null"` // FK to users.id
-	Status    string    `gorm:"type:enum('Pending','In Progress','In Review','Completed');default:'Pending';comment:0=Pending,1=In Progress,2=In Review,3=Completed"`
-	CreatedAt time.Time `gorm:"type:timestamp;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"type:timestamp;autoUpdateTime"`
+	ID        uint           `gorm:"primaryKey"`
+	TaskID    uint           `gorm:"not null"` // FK to tasks.id
+	UserID    uint           `gorm:"not null"` // FK to users.id
+	Status    string         `gorm:"type:enum('Pending','In Progress','In Review','Completed');default:'Pending';comment:0=Pending,1=In Progress,2=In Review,3=Completed"`
+	Progress  *uint          `gorm:"type:tinyint unsigned;comment:percent"` // nil when the update did not report progress
+	Note      string         `gorm:"type:text"`
+	CreatedAt time.Time      `gorm:"type:timestamp;autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"type:timestamp;autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	User      User           `gorm:"foreignKey:UserID"`
 }
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskTypeField represents a custom field defined on a task type
+type TaskTypeField struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TaskTypeID uint      `gorm:"not null;uniqueIndex:idx_task_type_field_key" json:"task_type_id"` // FK to task_types.id
+	Key        string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_task_type_field_key" json:"key"`
+	Label      string    `gorm:"type:varchar(255);not null" json:"label"`
+	FieldType  string    `gorm:"type:enum('text','number','date','select','multi_select','user');not null" json:"field_type"`
+	Required   bool      `gorm:"default:false" json:"required"`
+	Options    string    `gorm:"type:text" json:"-"` // JSON array of allowed values for select fields
+	Position   int       `gorm:"default:0" json:"position"`
+	CreatedAt  time.Time `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"type:timestamp;autoUpdateTime" json:"updated_at"`
+
+	OptionList []string `gorm:"-" json:"options"`
+}
+
+// BeforeSave stores OptionList as JSON in the Options column
+func (f *TaskTypeField) BeforeSave(tx *gorm.DB) error {
+	if f.OptionList == nil {
+		f.Options = "[]"
+		return nil
+	}
+	options, err := json.Marshal(f.OptionList)
+	if err != nil {
+		return err
+	}
+	f.Options = string(options)
+	return nil
+}
+
+// AfterFind fills OptionList from the Options column
+func (f *TaskTypeField) AfterFind(tx *gorm.DB) error {
+	f.OptionList = []string{}
+	if f.Options == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(f.Options), &f.OptionList)
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskWorklog records time spent on a task by a user.
+// A row with a nil EndedAt is a running timer; each user has at most one.
+type TaskWorklog struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	TaskID    uint           `gorm:"not null;index" json:"task_id"` // FK to tasks.id
+	UserID    uint           `gorm:"not null;index" json:"user_id"` // FK to users.id
+	StartedAt time.Time      `gorm:"type:timestamp;not null" json:"started_at"`
+	EndedAt   *time.Time     `gorm:"type:timestamp;null" json:"ended_at"`
+	Minutes   uint           `gorm:"not null;default:0" json:"minutes"`
+	Note      string         `gorm:"type:text" json:"note"`
+	CreatedAt time.Time      `gorm:"type:timestamp;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"type:timestamp;autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	User      User           `gorm:"foreignKey:UserID" json:"user"`
+}
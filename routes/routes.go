@@ -12,6 +12,7 @@ func SetupRoutes(r *gin.Engine) {
 	r.POST("/register", controllers.Register)
 	r.POST("/login", controllers.Login)
 	r.GET("/sync-user", controllers.SyscUser)
+	r.GET("/calendar/:file", controllers.GetCalendarFeed) // secret feed URL, no authentication
 
 	// Authenticated routes
 	auth := r.Group("/")
@@ -30,20 +31,106 @@ func SetupRoutes(r *gin.Engine) {
 		auth.GET("/task-types/:id", controllers.GetTaskTypeByID)
 		auth.PUT("/task-types/:id", controllers.UpdateTaskType)
 		auth.DELETE("/task-types/:id", controllers.DeleteTaskType)
+		auth.POST("/task-types/:id/fields", controllers.CreateTaskTypeField)
+		auth.GET("/task-types/:id/fields", controllers.GetTaskTypeFields)
+		auth.PUT("/task-type-fields/:id", controllers.UpdateTaskTypeField)
+		auth.DELETE("/task-type-fields/:id", controllers.DeleteTaskTypeField)
+
+		// Tag routes
+		auth.POST("/tags", controllers.CreateTag)
+		auth.GET("/tags", controllers.GetTags)
+		auth.PUT("/tags/:id", controllers.UpdateTag)
+		auth.POST("/tags/:id/merge", controllers.MergeTag)
+		auth.DELETE("/tags/:id", controllers.DeleteTag)
 
 		// User routes
 		auth.GET("/users", controllers.GetUsers)
 
 		// Task routes
 		auth.POST("/tasks", controllers.CreateTask)
+		auth.POST("/tasks/bulk", controllers.BulkUpdateTasks)
 		auth.GET("/tasks", controllers.GetTasks)
 		auth.GET("/my-tasks", controllers.GetMyTasks)
 		auth.POST("/my-tasks/filter", controllers.GetMyTasksFiltered) // New route for filtered My Tasks
 		auth.GET("/tasks/:id", controllers.GetTaskByID)
 		auth.PUT("/tasks/:id", controllers.UpdateTask)
+		auth.PATCH("/tasks/:id", controllers.PatchTask)
 		auth.DELETE("/tasks/:id", controllers.DeleteTask)
 		auth.POST("/tasks/:id/status", controllers.UpdateTaskStatus)
+		auth.POST("/tasks/:id/progress", controllers.UpdateTaskProgress)
+		auth.GET("/tasks/:id/progress", controllers.GetTaskProgressTimeline)
+		auth.POST("/tasks/:id/assignment/accept", controllers.AcceptTaskAssignment)
+		auth.POST("/tasks/:id/assignment/decline", controllers.DeclineTaskAssignment)
+		auth.GET("/assignments/unacknowledged", controllers.GetUnacknowledgedAssignments)
+		auth.POST("/tasks/:id/move", controllers.MoveTask)
 		auth.POST("/tasks/:id/comments", controllers.AddTaskComment)
+		auth.GET("/tasks/:id/comments", controllers.GetTaskComments)
+		auth.GET("/tasks/:id/attachment", controllers.GetTaskAttachment)
+		auth.GET("/tasks/:id/mail-attachments/:file", controllers.GetTaskMailAttachment)
+		auth.POST("/tasks/:id/clone", controllers.CloneTask)
+		auth.GET("/tasks/:id/seen-by", controllers.GetTaskSeenBy)
+		auth.GET("/tasks/:id/history", controllers.GetTaskHistory)
+		auth.GET("/tasks/:id/dependencies", controllers.GetTaskDependencies)
+		auth.POST("/tasks/:id/dependencies", controllers.AddTaskDependency)
+		auth.DELETE("/tasks/:id/dependencies/:dependsOnId", controllers.RemoveTaskDependency)
+
+		// Board routes
+		auth.GET("/board", controllers.GetBoard)
+
+		// Export routes
+		auth.GET("/exports/tasks", controllers.ExportTasks)
+
+		// Import routes
+		auth.POST("/imports", controllers.ImportTasks)
+		auth.GET("/imports", controllers.GetImportJobs)
+		auth.GET("/imports/:id", controllers.GetImportJob)
+
+		// Saved view routes
+		auth.POST("/views", controllers.CreateSavedView)
+		auth.GET("/views", controllers.GetSavedViews)
+		auth.DELETE("/views/default", controllers.ClearDefaultSavedView)
+		auth.GET("/views/:id", controllers.GetSavedViewByID)
+		auth.PUT("/views/:id", controllers.UpdateSavedView)
+		auth.DELETE("/views/:id", controllers.DeleteSavedView)
+		auth.POST("/views/:id/default", controllers.SetDefaultSavedView)
+		auth.GET("/views/:id/tasks", controllers.GetSavedViewTasks)
+
+		// Timeline routes
+		auth.GET("/timeline", controllers.GetTimeline)
+
+		// Calendar feed routes
+		auth.POST("/calendar-feeds", controllers.CreateCalendarFeed)
+		auth.GET("/calendar-feeds", controllers.GetCalendarFeeds)
+		auth.DELETE("/calendar-feeds/:id", controllers.RevokeCalendarFeed)
+
+		// Inbound mail routes (super admins)
+		auth.GET("/mail-senders", controllers.GetMailSenders)
+		auth.POST("/mail-senders", controllers.CreateMailSender)
+		auth.DELETE("/mail-senders/:id", controllers.DeleteMailSender)
+		auth.GET("/inbound-mails", controllers.GetInboundMails)
+
+		// Comment routes
+		auth.PUT("/comments/:id", controllers.UpdateTaskComment)
+		auth.DELETE("/comments/:id", controllers.DeleteTaskComment)
+		auth.GET("/comments/:id/revisions", controllers.GetCommentRevisions)
+		auth.POST("/comments/:id/reactions", controllers.AddCommentReaction)
+		auth.DELETE("/comments/:id/reactions/:emoji", controllers.RemoveCommentReaction)
+
+		// Trash routes
+		auth.GET("/trash", controllers.GetTrash)
+		auth.POST("/tasks/:id/restore", controllers.RestoreTask)
+		auth.POST("/trash/purge", controllers.PurgeTrash)
+
+		// Time tracking routes
+		auth.POST("/tasks/:id/timer/start", controllers.StartTaskTimer)
+		auth.POST("/timer/stop", controllers.StopTimer)
+		auth.GET("/timer", controllers.GetRunningTimer)
+		auth.POST("/tasks/:id/worklogs", controllers.AddTaskWorklog)
+		auth.GET("/tasks/:id/worklogs", controllers.GetTaskWorklogs)
+		auth.DELETE("/worklogs/:id", controllers.DeleteWorklog)
+		auth.GET("/tasks/:id/time-totals", controllers.GetTaskTimeTotals)
+		auth.GET("/users/:id/time-totals", controllers.GetUserTimeTotals)
+		auth.GET("/groups/:id/time-totals", controllers.GetGroupTimeTotals)
 
 		// UserGroup routes
 		auth.POST("/user-groups", controllers.AssignUsersToGroup)
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"taskmanager/models"
+
+	"gorm.io/gorm"
+)
+
+// processUnacceptedAssignments escalates open tasks that have pending assignments older
+// than the configured number of hours and that none of their assignees accepted
+func processUnacceptedAssignments(db *gorm.DB, cfg Config, now time.Time) error {
+	if cfg.AcceptWithinHours == 0 {
+		return nil
+	}
+	cutoff := now.Add(-time.Duration(cfg.AcceptWithinHours) * time.Hour)
+
+	var tasks []models.Task
+	if err := db.Where("status <> ? AND priority <> ?", "Completed", "Escalation").
+		Where("id IN (?)", db.Model(&models.AssignTaskToUser{}).Select("task_id").
+			Where("status = ? AND created_at <= ?", models.AssignmentPending, cutoff)).
+		Where("id NOT IN (?)", db.Model(&models.AssignTaskToUser{}).Select("task_id").
+			Where("status = ?", models.AssignmentAccepted)).
+		Find(&tasks).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, task := range tasks {
+		message := fmt.Sprintf("Task '%s' was not accepted within %d hour(s); priority raised from %s to Escalation",
+			task.NotificationLabel(), cfg.AcceptWithinHours, task.Priority)
+		// Keyed on the creation date: a task is escalated for missing acceptance at most once
+		err := runStep(db, task, dateOnly(task.CreatedAt), "unaccepted", "escalation", message, func(tx *gorm.DB) error {
+			if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+				"priority": "Escalation",
+				"version":  gorm.Expr("version + 1"),
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.TaskEscalationLog{
+				TaskID:       task.ID,
+				FromPriority: task.Priority,
+				ToPriority:   "Escalation",
+				Reason:       "unaccepted",
+			}).Error; err != nil {
+				return err
+			}
+			// System changes have no user
+			return tx.Create(&models.TaskHistory{
+				TaskID:   task.ID,
+				Field:    "priority",
+				OldValue: task.Priority,
+				NewValue: "Escalation",
+			}).Error
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("task %d: %w", task.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
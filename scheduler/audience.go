@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"taskmanager/models"
+
+	"gorm.io/gorm"
+)
+
+// taskAudience returns the creator, assignees (except those who declined) and follow-ups of a task,
+// with group assignments and follow-up groups expanded to their members.
+// Groups are not expanded for restricted and private tasks.
+func taskAudience(db *gorm.DB, task models.Task) ([]uint, error) {
+	userMap := map[uint]bool{task.CreatedBy: true}
+
+	var userIDs []uint
+	if err := db.Model(&models.AssignTaskToUser{}).Where("task_id = ? AND status <> ?", task.ID, models.AssignmentDeclined).Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range userIDs {
+		userMap[id] = true
+	}
+
+	userIDs = nil
+	if err := db.Model(&models.TaskFollowupUser{}).Where("task_id = ?", task.ID).Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range userIDs {
+		userMap[id] = true
+	}
+
+	if task.IsConfidential() {
+		return audienceList(userMap), nil
+	}
+
+	userIDs = nil
+	if err := db.Model(&models.UserGroup{}).
+		Joins("JOIN assign_task_to_groups ON user_groups.group_id = assign_task_to_groups.group_id AND assign_task_to_groups.deleted_at IS NULL").
+		Where("assign_task_to_groups.task_id = ?", task.ID).
+		Pluck("user_groups.user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range userIDs {
+		userMap[id] = true
+	}
+
+	userIDs = nil
+	if err := db.Model(&models.UserGroup{}).
+		Joins("JOIN task_followup_groups ON user_groups.group_id = task_followup_groups.group_id AND task_followup_groups.deleted_at IS NULL").
+		Where("task_followup_groups.task_id = ?", task.ID).
+		Pluck("user_groups.user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range userIDs {
+		userMap[id] = true
+	}
+
+	return audienceList(userMap), nil
+}
+
+func audienceList(userMap map[uint]bool) []uint {
+	var audience []uint
+	for id := range userMap {
+		audience = append(audience, id)
+	}
+	return audience
+}
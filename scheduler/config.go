@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config controls how often the scheduler runs and which due-date rules it applies
+type Config struct {
+	// Interval between two scheduler runs
+	Interval time.Duration
+	// ReminderDays lists how many days before the due date reminders are sent, e.g. [3, 1, 0]
+	ReminderDays []int
+	// HighAfterDays raises the priority to High once a task is overdue for this many days (0 disables)
+	HighAfterDays int
+	// EscalateAfterDays raises the priority to Escalation once a task is overdue for this many days (0 disables)
+	EscalateAfterDays int
+	// AcceptWithinHours raises the priority to Escalation when none of the assignees accepted
+	// the task this many hours after it was assigned (0 disables)
+	AcceptWithinHours int
+}
+
+// LoadConfig reads the scheduler configuration from the environment, falling back to defaults:
+//
+//	SCHEDULER_INTERVAL_MINUTES  (default 15)
+//	REMINDER_DAYS_BEFORE_DUE    (default "3,1,0")
+//	ESCALATE_HIGH_AFTER_DAYS    (default 1)
+//	ESCALATE_AFTER_DAYS         (default 3)
+//	ASSIGNMENT_ACCEPT_HOURS     (default 0, disabled)
+func LoadConfig() Config {
+	return Config{
+		Interval:          time.Duration(envPositiveInt("SCHEDULER_INTERVAL_MINUTES", 15)) * time.Minute,
+		ReminderDays:      envIntList("REMINDER_DAYS_BEFORE_DUE", []int{3, 1, 0}),
+		HighAfterDays:     envInt("ESCALATE_HIGH_AFTER_DAYS", 1),
+		EscalateAfterDays: envInt("ESCALATE_AFTER_DAYS", 3),
+		AcceptWithinHours: envInt("ASSIGNMENT_ACCEPT_HOURS", 0),
+	}
+}
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("scheduler: ignoring invalid %s=%q", key, value)
+		return fallback
+	}
+	return n
+}
+
+// envPositiveInt is envInt for values that cannot be 0
+func envPositiveInt(key string, fallback int) int {
+	n := envInt(key, fallback)
+	if n == 0 {
+		log.Printf("scheduler: ignoring invalid %s=%q", key, os.Getenv(key))
+		return fallback
+	}
+	return n
+}
+
+func envIntList(key string, fallback []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	var list []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			log.Printf("scheduler: ignoring invalid %s=%q", key, value)
+			return fallback
+		}
+		list = append(list, n)
+	}
+	return list
+}
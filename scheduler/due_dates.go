@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"taskmanager/models"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// processDueDates sends due-date reminders, flags overdue tasks and escalates their priority
+func processDueDates(db *gorm.DB, cfg Config, now time.Time) error {
+	today := dateOnly(now)
+
+	// Tasks that were completed or rescheduled are no longer overdue
+	if err := db.Model(&models.Task{}).
+		Where("overdue_since IS NOT NULL AND (status = ? OR due_date IS NULL OR due_date >= ?)", "Completed", today).
+		Update("overdue_since", nil).Error; err != nil {
+		return err
+	}
+
+	offsets := append([]int(nil), cfg.ReminderDays...)
+	sort.Ints(offsets)
+	horizon := 0
+	if len(offsets) > 0 {
+		horizon = offsets[len(offsets)-1]
+	}
+
+	var tasks []models.Task
+	if err := db.Where("status <> ? AND due_date IS NOT NULL AND due_date <= ?", "Completed", today.AddDate(0, 0, horizon)).
+		Find(&tasks).Error; err != nil {
+		return err
+	}
+
+	// One failing task must not hold back the others, the errors are reported together
+	var errs []error
+	for _, task := range tasks {
+		due := dateOnly(*task.DueDate)
+		daysLeft := daysBetween(today, due)
+
+		var err error
+		if daysLeft >= 0 {
+			err = sendReminder(db, task, due, daysLeft, offsets)
+		} else {
+			err = handleOverdue(db, cfg, task, due, -daysLeft, now)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("task %d: %w", task.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendReminder sends the reminder for the closest configured offset the task has reached.
+// Larger offsets that were missed (e.g. a task created the day before it is due) are skipped.
+func sendReminder(db *gorm.DB, task models.Task, due time.Time, daysLeft int, offsets []int) error {
+	for _, offset := range offsets {
+		if daysLeft > offset {
+			continue
+		}
+
+		message := fmt.Sprintf("Task '%s' is due in %d day(s)", task.NotificationLabel(), daysLeft)
+		if daysLeft == 0 {
+			message = fmt.Sprintf("Task '%s' is due today", task.NotificationLabel())
+		}
+		return runStep(db, task, due, fmt.Sprintf("reminder_%dd", offset), "due_reminder", message, nil)
+	}
+	return nil
+}
+
+// handleOverdue flags an overdue task, notifies its audience once and escalates its priority
+func handleOverdue(db *gorm.DB, cfg Config, task models.Task, due time.Time, daysOverdue int, now time.Time) error {
+	if task.OverdueSince == nil {
+		if err := db.Model(&models.Task{}).Where("id = ? AND overdue_since IS NULL", task.ID).Update("overdue_since", now).Error; err != nil {
+			return err
+		}
+	}
+
+	message := fmt.Sprintf("Task '%s' is overdue since %s", task.NotificationLabel(), due.Format("2006-01-02"))
+	if err := runStep(db, task, due, "overdue", "overdue", message, nil); err != nil {
+		return err
+	}
+
+	target := ""
+	if cfg.EscalateAfterDays > 0 && daysOverdue >= cfg.EscalateAfterDays && task.Priority != "Escalation" {
+		target = "Escalation"
+	} else if cfg.HighAfterDays > 0 && daysOverdue >= cfg.HighAfterDays && (task.Priority == "Normal" || task.Priority == "Medium") {
+		target = "High"
+	}
+	if target == "" {
+		return nil
+	}
+
+	message = fmt.Sprintf("Task '%s' is %d day(s) overdue; priority raised from %s to %s", task.NotificationLabel(), daysOverdue, task.Priority, target)
+	return runStep(db, task, due, "priority_"+target, "escalation", message, func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+			"priority": target,
+			"version":  gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.TaskEscalationLog{
+			TaskID:       task.ID,
+			FromPriority: task.Priority,
+			ToPriority:   target,
+			DaysOverdue:  daysOverdue,
+			Reason:       "overdue",
+		}).Error; err != nil {
+			return err
+		}
+		// System changes have no user
+		return tx.Create(&models.TaskHistory{
+			TaskID:   task.ID,
+			Field:    "priority",
+			OldValue: task.Priority,
+			NewValue: target,
+		}).Error
+	})
+}
+
+// runStep records a reminder step, applies its change and notifies the task audience in one transaction.
+// A step that was already recorded for the same due date is silently skipped.
+func runStep(db *gorm.DB, task models.Task, due time.Time, kind, notificationType, message string, apply func(tx *gorm.DB) error) error {
+	audience, err := taskAudience(db, task)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		step := models.TaskReminder{TaskID: task.ID, Kind: kind, DueDate: due}
+		if err := tx.Create(&step).Error; err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+				return nil
+			}
+			return err
+		}
+
+		if apply != nil {
+			if err := apply(tx); err != nil {
+				return err
+			}
+		}
+
+		var notifications []models.Notification
+		for _, userID := range audience {
+			notifications = append(notifications, models.Notification{
+				UserID:  userID,
+				TaskID:  &task.ID,
+				Type:    notificationType,
+				Message: message,
+			})
+		}
+		if len(notifications) == 0 {
+			return nil
+		}
+		return tx.Create(&notifications).Error
+	})
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockName is the MySQL named lock that makes sure only one replica runs the jobs at a time
+const lockName = "taskmanager_scheduler"
+
+// job is a unit of periodic background work
+type job struct {
+	name string
+	run  func(db *gorm.DB, cfg Config, now time.Time) error
+}
+
+var jobs = []job{
+	{name: "due dates", run: processDueDates},
+	{name: "unaccepted assignments", run: processUnacceptedAssignments},
+}
+
+// Start runs the scheduler in the background until the process exits
+func Start(db *gorm.DB, cfg Config) {
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		RunOnce(db, cfg)
+		for range ticker.C {
+			RunOnce(db, cfg)
+		}
+	}()
+}
+
+// RunOnce runs every job once if this replica can take the scheduler lock.
+// When another replica holds the lock the run is skipped.
+func RunOnce(db *gorm.DB, cfg Config) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Printf("scheduler: %v", err)
+		return
+	}
+
+	ctx := context.Background()
+
+	// GET_LOCK is bound to the session, so hold one connection for the whole run
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		log.Printf("scheduler: failed to get connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", lockName).Scan(&acquired); err != nil {
+		log.Printf("scheduler: failed to acquire lock: %v", err)
+		return
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+
+	now := time.Now()
+	for _, j := range jobs {
+		if err := j.run(db, cfg, now); err != nil {
+			log.Printf("scheduler: %s job failed: %v", j.name, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"strings"
+	"time"
+)
+
+// ICalWriter builds an RFC 5545 iCalendar document
+type ICalWriter struct {
+	b strings.Builder
+}
+
+// Line writes a content line, folding it at 75 octets as required by RFC 5545
+func (w *ICalWriter) Line(name string, value string) {
+	line := name + ":" + value
+	// Continuation lines start with a space, which counts towards their 75 octets
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Do not split a multi-byte UTF-8 character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.b.WriteString(line[:cut])
+		w.b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	w.b.WriteString(line)
+	w.b.WriteString("\r\n")
+}
+
+// Text writes a content line with a TEXT value, escaping it
+func (w *ICalWriter) Text(name string, value string) {
+	w.Line(name, ICalEscape(value))
+}
+
+// Date writes a content line with a DATE value
+func (w *ICalWriter) Date(name string, t time.Time) {
+	w.Line(name+";VALUE=DATE", t.Format("20060102"))
+}
+
+// Timestamp writes a content line with a UTC DATE-TIME value
+func (w *ICalWriter) Timestamp(name string, t time.Time) {
+	w.Line(name, t.UTC().Format("20060102T150405Z"))
+}
+
+// String returns the document
+func (w *ICalWriter) String() string {
+	return w.b.String()
+}
+
+// ICalEscape escapes a TEXT value
+func ICalEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
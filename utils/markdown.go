@@ -0,0 +1,542 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RenderMarkdown converts Markdown (CommonMark basics plus GitHub's fenced code, tables,
+// strikethrough and autolinks) to sanitized HTML. Line breaks inside a paragraph are kept,
+// as in comments on GitHub. Raw HTML is allowed in the source, which keeps content written
+// with the rich text editor rendering, but like everything else it only survives the
+// SanitizeHTML allowlist. taskRef, if not nil, links task references such as #123.
+func RenderMarkdown(src string, taskRef TaskRefFunc) string {
+	if strings.TrimSpace(src) == "" {
+		return ""
+	}
+	src = strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\r", "\n")
+	src = strings.ReplaceAll(src, "\t", "    ")
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), false, 0)
+	return SanitizeHTML(b.String(), taskRef)
+}
+
+var (
+	mdHeadingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ ]+(.*?))?(?:[ ]+#+)?[ ]*$`)
+	mdRulePattern       = regexp.MustCompile(`^ {0,3}(?:(?:-[ ]*){3,}|(?:\*[ ]*){3,}|(?:_[ ]*){3,})$`)
+	mdFencePattern      = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ ]*([^` ]*)")
+	mdQuotePattern      = regexp.MustCompile(`^ {0,3}> ?`)
+	mdListItemPattern   = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:[ ]+(.*))?$`)
+	mdHTMLBlockPattern  = regexp.MustCompile(`(?i)^ {0,3}</?(p|div|ul|ol|li|h[1-6]|blockquote|pre|table|thead|tbody|tr|td|th|hr|br|img|figure|section)[\s/>]`)
+	mdTableSepPattern   = regexp.MustCompile(`^ {0,3}\|?[ ]*:?-+:?[ ]*(\|[ ]*:?-+:?[ ]*)*\|?[ ]*$`)
+	mdLanguagePattern   = regexp.MustCompile(`^[A-Za-z0-9_+-]+$`)
+	mdEntityPattern     = regexp.MustCompile(`^&(?:[A-Za-z][A-Za-z0-9]{1,31}|#[0-9]{1,7}|#[xX][0-9A-Fa-f]{1,6});`)
+	mdAutolinkPattern   = regexp.MustCompile(`^<((?:https?://|mailto:)[^\s<>]+)>`)
+	mdRawTagPattern     = regexp.MustCompile(`^</?[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][-A-Za-z0-9_:.]*(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*\s*/?>`)
+	mdBareURLPattern    = regexp.MustCompile(`^(?:https?://|www\.)[^\s<]+`)
+	mdLinkTargetPattern = regexp.MustCompile(`^\(\s*(<[^<>\n]*>|[^\s()]*(?:\([^\s()]*\)[^\s()]*)*)(?:\s+("[^"]*"|'[^']*'))?\s*\)`)
+)
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// startsBlock reports whether a line interrupts a paragraph
+func startsBlock(line string) bool {
+	return mdHeadingPattern.MatchString(line) || mdRulePattern.MatchString(line) ||
+		mdFencePattern.MatchString(line) || mdQuotePattern.MatchString(line) ||
+		mdHTMLBlockPattern.MatchString(line) ||
+		startsListItem(line)
+}
+
+// startsListItem reports whether a line starts a non-empty list item that can interrupt a
+// paragraph: ordered lists must start at 1, so that "2019. was a year" stays text
+func startsListItem(line string) bool {
+	m := mdListItemPattern.FindStringSubmatch(line)
+	return m != nil && !isBlank(m[3]) && (len(m[2]) == 1 || m[2][:len(m[2])-1] == "1")
+}
+
+// maxBlockNesting is how deep quotes and lists may nest. Deeper content is kept as text,
+// since each level renders its lines again.
+const maxBlockNesting = 8
+
+// renderBlocks renders a sequence of lines nested depth quotes or lists deep. Tight list
+// items render their paragraphs without <p> tags.
+func renderBlocks(b *strings.Builder, lines []string, tight bool, depth int) {
+	if depth > maxBlockNesting {
+		b.WriteString("<p>" + html.EscapeString(strings.Join(lines, "\n")) + "</p>\n")
+		return
+	}
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case mdFencePattern.MatchString(line):
+			m := mdFencePattern.FindStringSubmatch(line)
+			fence := m[1]
+			i++
+			var code []string
+			for ; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) && strings.Trim(strings.TrimSpace(lines[i]), fence[:1]) == "" {
+					i++
+					break
+				}
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code")
+			if mdLanguagePattern.MatchString(m[2]) {
+				b.WriteString(` class="language-` + m[2] + `"`)
+			}
+			b.WriteString(">")
+			for _, c := range code {
+				b.WriteString(html.EscapeString(c) + "\n")
+			}
+			b.WriteString("</code></pre>\n")
+
+		case mdHeadingPattern.MatchString(line):
+			m := mdHeadingPattern.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case mdRulePattern.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case mdQuotePattern.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				quoted = append(quoted, mdQuotePattern.ReplaceAllString(lines[i], ""))
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, false, depth+1)
+			b.WriteString("</blockquote>\n")
+
+		case mdListItemPattern.MatchString(line):
+			i = renderList(b, lines, i, depth)
+
+		case mdHTMLBlockPattern.MatchString(line):
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				b.WriteString(lines[i] + "\n")
+			}
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && mdTableSepPattern.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			i = renderTable(b, lines, i)
+
+		default:
+			var paragraph []string
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				if len(paragraph) > 0 && startsBlock(lines[i]) {
+					break
+				}
+				paragraph = append(paragraph, lines[i])
+			}
+			text := renderInline(strings.Join(paragraph, "\n"))
+			if tight {
+				b.WriteString(text + "\n")
+			} else {
+				b.WriteString("<p>" + text + "</p>\n")
+			}
+		}
+	}
+}
+
+// renderList renders the list starting at lines[start] and returns the index of the line
+// after it. Item content is indented by at least two spaces or lazily continues a
+// paragraph; a nested list is indented under its item.
+func renderList(b *strings.Builder, lines []string, start int, depth int) int {
+	first := mdListItemPattern.FindStringSubmatch(lines[start])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+	delimiter := first[2][len(first[2])-1:]
+	sameList := func(m []string) bool {
+		isOrdered := m[2][0] >= '0' && m[2][0] <= '9'
+		return isOrdered == ordered && (!ordered || strings.HasSuffix(m[2], delimiter)) && (ordered || m[2] == first[2])
+	}
+
+	baseIndent := len(first[1])
+
+	var items [][]string
+	loose := false
+	i := start
+	for i < len(lines) {
+		line := lines[i]
+		if m := mdListItemPattern.FindStringSubmatch(line); m != nil && len(m[1]) < baseIndent+2 {
+			if !sameList(m) {
+				break
+			}
+			items = append(items, []string{m[3]})
+			i++
+			continue
+		}
+		item := &items[len(items)-1]
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case isBlank(line):
+			// A blank line continues the list only if it goes on after it
+			next := i + 1
+			for next < len(lines) && isBlank(lines[next]) {
+				next++
+			}
+			if next == len(lines) {
+				return finishList(b, items, ordered, first[2], loose, depth, next)
+			}
+			nextIndent := len(lines[next]) - len(strings.TrimLeft(lines[next], " "))
+			m := mdListItemPattern.FindStringSubmatch(lines[next])
+			if nextIndent < baseIndent+2 && (m == nil || !sameList(m)) {
+				return finishList(b, items, ordered, first[2], loose, depth, i)
+			}
+			loose = true
+			*item = append(*item, "")
+			i++
+		case indent >= baseIndent+2:
+			strip := indent
+			if strip > baseIndent+4 {
+				strip = baseIndent + 4
+			}
+			*item = append(*item, line[strip:])
+			i++
+		case !isBlank((*item)[len(*item)-1]) && !startsBlock(line):
+			// Lazy continuation of the item's paragraph
+			*item = append(*item, strings.TrimLeft(line, " "))
+			i++
+		default:
+			return finishList(b, items, ordered, first[2], loose, depth, i)
+		}
+	}
+	return finishList(b, items, ordered, first[2], loose, depth, i)
+}
+
+func finishList(b *strings.Builder, items [][]string, ordered bool, marker string, loose bool, depth int, next int) int {
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if ordered {
+		if n, err := strconv.Atoi(marker[:len(marker)-1]); err == nil && n != 1 {
+			b.WriteString(` start="` + strconv.Itoa(n) + `"`)
+		}
+	}
+	b.WriteString(">\n")
+	for _, item := range items {
+		b.WriteString("<li>")
+		renderBlocks(b, item, !loose, depth+1)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return next
+}
+
+// splitTableRow splits a table row into trimmed cells, honouring escaped pipes
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func renderTable(b *strings.Builder, lines []string, start int) int {
+	header := splitTableRow(lines[start])
+	var aligns []string
+	for _, sep := range splitTableRow(lines[start+1]) {
+		switch {
+		case strings.HasPrefix(sep, ":") && strings.HasSuffix(sep, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(sep, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(sep, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	row := func(cells []string, tag string) {
+		b.WriteString("<tr>")
+		for i := range header {
+			b.WriteString("<" + tag)
+			if i < len(aligns) && aligns[i] != "" {
+				b.WriteString(` align="` + aligns[i] + `"`)
+			}
+			b.WriteString(">")
+			if i < len(cells) {
+				b.WriteString(renderInline(cells[i]))
+			}
+			b.WriteString("</" + tag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	row(header, "th")
+	b.WriteString("</thead>\n<tbody>\n")
+	i := start + 2
+	for ; i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|"); i++ {
+		row(splitTableRow(lines[i]), "td")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// findCloser finds the closing emphasis delimiter for an opener at s[:len(delim)], which
+// must not be followed by a space, and whose closer must not follow one
+func findCloser(s string, delim string) int {
+	if len(s) <= len(delim) || s[len(delim)] == ' ' || s[len(delim)] == '\n' {
+		return -1
+	}
+	for i := len(delim) + 1; i+len(delim) <= len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '`':
+			// Delimiters inside code spans do not count
+			if end := strings.Index(s[i+1:], "`"); end >= 0 {
+				i += end + 1
+			}
+		case strings.HasPrefix(s[i:], delim) && s[i-1] != ' ' && s[i-1] != '\n':
+			if delim[0] == '_' && i+len(delim) < len(s) && isWordByte(s[i+len(delim)]) {
+				continue
+			}
+			if len(delim) == 1 && (s[i-1] == delim[0] || i+1 < len(s) && s[i+1] == delim[0]) {
+				// Part of a longer run, e.g. the closer of a nested strong span
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// maxInlineNesting is how deep links and emphasis may nest. Deeper content is kept as text,
+// since each level renders its content again.
+const maxInlineNesting = 16
+
+// matchBrackets maps the position of each [ in s to that of its matching ], skipping
+// escaped brackets. Unmatched brackets are left out.
+func matchBrackets(s string) map[int]int {
+	closers := make(map[int]int)
+	var open []int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			open = append(open, i)
+		case ']':
+			if len(open) > 0 {
+				closers[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return closers
+}
+
+// renderInline renders the inline elements of a paragraph, heading or cell
+func renderInline(s string) string {
+	return renderInlineNested(s, 0)
+}
+
+// renderInlineNested renders inline elements at a nesting depth. Brackets are matched in
+// one pass, and a scan for a closing backtick run or emphasis delimiter that fails is not
+// repeated for later openers, since they would find nothing either. This keeps the time
+// spent close to linear in the length of s.
+func renderInlineNested(s string, depth int) string {
+	if depth > maxInlineNesting {
+		return html.EscapeString(s)
+	}
+
+	closers := matchBrackets(s)
+	unclosedRun := 0                  // shortest backtick run found without a closer so far
+	noCloser := make(map[string]bool) // emphasis delimiters found without a closer so far
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		rest := s[i:]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+
+		case c == '\n':
+			b.WriteString("<br>\n")
+			i++
+
+		case c == ' ' && strings.HasPrefix(rest, "  \n"):
+			i += 2
+
+		case c == '`':
+			run := len(rest) - len(strings.TrimLeft(rest, "`"))
+			end := -1
+			if unclosedRun == 0 || run < unclosedRun {
+				end = strings.Index(rest[run:], rest[:run])
+			}
+			if end < 0 {
+				unclosedRun = run
+				b.WriteString(rest[:run])
+				i += run
+				break
+			}
+			code := strings.ReplaceAll(rest[run:run+end], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			i += run + end + run
+
+		case c == '<' && mdAutolinkPattern.MatchString(rest):
+			m := mdAutolinkPattern.FindStringSubmatch(rest)
+			b.WriteString(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(strings.TrimPrefix(m[1], "mailto:")) + "</a>")
+			i += len(m[0])
+
+		case c == '<' && mdRawTagPattern.MatchString(rest):
+			tag := mdRawTagPattern.FindString(rest)
+			b.WriteString(tag)
+			i += len(tag)
+
+		case c == '&' && mdEntityPattern.MatchString(rest):
+			entity := mdEntityPattern.FindString(rest)
+			b.WriteString(entity)
+			i += len(entity)
+
+		case c == '!' && strings.HasPrefix(rest, "!["):
+			if text, href, title, n, ok := parseLink(rest[1:], closers[i+1]-i-1); ok {
+				b.WriteString(`<img src="` + html.EscapeString(href) + `" alt="` + html.EscapeString(text) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">")
+				i += 1 + n
+				break
+			}
+			b.WriteString("!")
+			i++
+
+		case c == '[':
+			if text, href, title, n, ok := parseLink(rest, closers[i]-i); ok {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">" + renderInlineNested(text, depth+1) + "</a>")
+				i += n
+				break
+			}
+			b.WriteString("[")
+			i++
+
+		case (c == 'h' || c == 'w') && (i == 0 || !isWordByte(s[i-1])) && mdBareURLPattern.MatchString(rest):
+			link := trimURL(mdBareURLPattern.FindString(rest))
+			href := link
+			if strings.HasPrefix(link, "www.") {
+				href = "http://" + link
+			}
+			b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(link) + "</a>")
+			i += len(link)
+
+		case c == '*' || c == '_' || c == '~':
+			delim := ""
+			for _, d := range []string{"**", "__", "~~", "*", "_"} {
+				if strings.HasPrefix(rest, d) {
+					delim = d
+					break
+				}
+			}
+			// Intraword underscores, as in snake_case, are literal
+			if delim == "" || (c == '_' && i > 0 && isWordByte(s[i-1])) {
+				b.WriteString(html.EscapeString(s[i : i+1]))
+				i++
+				break
+			}
+			end := -1
+			if !noCloser[delim] {
+				end = findCloser(rest, delim)
+				// An opener followed by a space fails without looking for a closer
+				if end < 0 && len(rest) > len(delim) && rest[len(delim)] != ' ' && rest[len(delim)] != '\n' {
+					noCloser[delim] = true
+				}
+			}
+			if end < 0 {
+				b.WriteString(html.EscapeString(delim))
+				i += len(delim)
+				break
+			}
+			tag := map[string]string{"**": "strong", "__": "strong", "~~": "del", "*": "em", "_": "em"}[delim]
+			b.WriteString("<" + tag + ">" + renderInlineNested(rest[len(delim):end], depth+1) + "</" + tag + ">")
+			i += end + len(delim)
+
+		default:
+			b.WriteString(html.EscapeString(s[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+// trimURL drops the trailing punctuation of a bare URL, keeping closing parentheses that
+// are balanced within it
+func trimURL(link string) string {
+	for len(link) > 0 {
+		last := link[len(link)-1]
+		if last == ')' && strings.Count(link, "(") >= strings.Count(link, ")") {
+			break
+		}
+		if strings.IndexByte(".,:;!?'\")*_~", last) < 0 {
+			break
+		}
+		link = link[:len(link)-1]
+	}
+	return link
+}
+
+// parseLink parses [text](url "title") at the start of s, given the position of the ]
+// matching the [, which is not positive when there is none
+func parseLink(s string, closer int) (text string, href string, title string, n int, ok bool) {
+	if closer <= 0 {
+		return "", "", "", 0, false
+	}
+	m := mdLinkTargetPattern.FindStringSubmatch(s[closer+1:])
+	if m == nil {
+		return "", "", "", 0, false
+	}
+	href = strings.TrimSuffix(strings.TrimPrefix(m[1], "<"), ">")
+	if len(m[2]) >= 2 {
+		title = m[2][1 : len(m[2])-1]
+	}
+	return s[1:closer], html.UnescapeString(href), html.UnescapeString(title), closer + 1 + len(m[0]), true
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs", "a\nb\n\nc", "<p>a<br>\nb</p>\n<p>c</p>\n"},
+		{"heading", "## Title ##", "<h2>Title</h2>\n"},
+		{"emphasis", "**bold *em* text** and ~~gone~~", "<p><strong>bold <em>em</em> text</strong> and <del>gone</del></p>\n"},
+		{"intraword underscores", "snake_case_name", "<p>snake_case_name</p>\n"},
+		{"unclosed emphasis", "*a and **b", "<p>*a and **b</p>\n"},
+		{"code span", "`a *b* <c>`", "<p><code>a *b* &lt;c&gt;</code></p>\n"},
+		{"fenced code", "```go\nx := `*`\n```", "<pre><code class=\"language-go\">x := `*`\n</code></pre>\n"},
+		{"link", `[the docs](https://x.io/a_(b) "T")`, `<p><a href="https://x.io/a_(b)" title="T" rel="nofollow noopener noreferrer">the docs</a></p>` + "\n"},
+		{"nested brackets in a link", "[a [b] c](/x)", `<p><a href="/x" rel="nofollow noopener noreferrer">a [b] c</a></p>` + "\n"},
+		{"unmatched brackets", "[a [b](/x)", `<p>[a <a href="/x" rel="nofollow noopener noreferrer">b</a></p>` + "\n"},
+		{"escaped bracket", `\[a](/x)`, "<p>[a](/x)</p>\n"},
+		{"image", `![alt](/a.png)`, `<p><img src="/a.png" alt="alt"></p>` + "\n"},
+		{"bare url", "see https://x.io/a).", `<p>see <a href="https://x.io/a" rel="nofollow noopener noreferrer">https://x.io/a</a>).</p>` + "\n"},
+		{"quote", "> a\n> > b", "<blockquote>\n<p>a</p>\n<blockquote>\n<p>b</p>\n</blockquote>\n</blockquote>\n"},
+		{"list", "- a\n  - b\n- c", "<ul>\n<li>a\n<ul>\n<li>b\n</li>\n</ul>\n</li>\n<li>c\n</li>\n</ul>\n"},
+		{"ordered list", "3. a\n4. b", "<ol start=\"3\">\n<li>a\n</li>\n<li>b\n</li>\n</ol>\n"},
+		{"table", "|a|b|\n|-|:-:|\n|1|2|", "<table>\n<thead>\n<tr><th>a</th><th align=\"center\">b</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td align=\"center\">2</td></tr>\n</tbody>\n</table>\n"},
+		{"raw html", "<b>hi</b> <script>alert(1)</script>", "<p><b>hi</b> </p>\n"},
+		{"empty", "  \n ", ""},
+	}
+
+	for _, tt := range tests {
+		if got := RenderMarkdown(tt.in, nil); got != tt.want {
+			t.Errorf("%s: RenderMarkdown(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownTaskRefs(t *testing.T) {
+	taskRef := func(id uint) (string, bool) { return "Task", id != 404 }
+	got := RenderMarkdown("#12, [#13](/x), `#14` and #404", taskRef)
+	want := `<p><a href="/tasks?task=12" class="task-ref" data-task-id="12" title="Task">#12</a>, <a href="/x" rel="nofollow noopener noreferrer">#13</a>, <code>#14</code> and #404</p>` + "\n"
+	if got != want {
+		t.Errorf("RenderMarkdown = %q, want %q", got, want)
+	}
+}
+
+// pathologicalMarkdown are inputs that take quadratic time to render when every unmatched
+// opener or nesting level scans the rest of the text
+func pathologicalMarkdown(n int) map[string]string {
+	return map[string]string{
+		"unmatched images":     strings.Repeat("![", n/2),
+		"unmatched brackets":   strings.Repeat("[", n),
+		"unclosed link":        strings.Repeat("[a](b", n/5),
+		"nested links":         strings.Repeat("[", n/8) + "x" + strings.Repeat("](u)", n/8),
+		"unclosed emphasis":    strings.Repeat("*a ", n/3),
+		"nested emphasis":      strings.Repeat("*a _a ", n/12) + strings.Repeat("a_ a* ", n/12),
+		"unclosed code spans":  "`" + strings.Repeat("a``", n/3),
+		"nested quotes":        strings.Repeat("> ", n/2) + "x",
+		"nested lists":         strings.Repeat("- ", n/2) + "x",
+		"unclosed html":        strings.Repeat("<b>", n/6) + strings.Repeat("</i>", n/8),
+		"unmatched everything": strings.Repeat("[*`_~<", n/6),
+	}
+}
+
+func TestRenderMarkdownPathologicalInput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("renders large inputs")
+	}
+	// Four times the longest description; quadratic rendering took seconds for this
+	const size = 200000
+	for name, in := range pathologicalMarkdown(size) {
+		start := time.Now()
+		RenderMarkdown(in, nil)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: rendering %d bytes took %v", name, len(in), elapsed)
+		}
+	}
+}
+
+func BenchmarkRenderMarkdownPathological(b *testing.B) {
+	for name, in := range pathologicalMarkdown(50000) {
+		b.Run(strings.ReplaceAll(name, " ", "_"), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				RenderMarkdown(in, nil)
+			}
+		})
+	}
+}
+
+func FuzzRenderMarkdown(f *testing.F) {
+	for _, seed := range []string{
+		"**a** _b_ `c` [d](/e \"f\") ![g](/h.png) <https://i.io> #1",
+		"> a\n- b\n  1. c\n\n|d|e|\n|-|-|\n|f|g|",
+		`<a href="javascript:alert(1)" onclick="x">a</a><script>b</script>`,
+		"[![a](b)](c) [[d]](e) ***f*** ~~~g~~~",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		assertSafeHTML(t, "fuzz", RenderMarkdown(in, func(id uint) (string, bool) { return `"><script>`, true }))
+	})
+}
@@ -6,18 +6,22 @@ import (
 	"time"
 )
 
-// NullableDate is a custom type for handling nullable date strings in YYYY-MM-DD format
+// NullableDate is a custom type for handling nullable date strings in YYYY-MM-DD format.
+// As a member of a JSON merge patch (RFC 7396), Present tells whether the member was sent
+// at all and Null whether it was sent as null (or empty).
 type NullableDate struct {
 	time.Time
 	Present bool
+	Null    bool
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
-// It handles parsing of date strings.
+// It handles parsing of date strings, and is only called for members that are present.
 func (d *NullableDate) UnmarshalJSON(data []byte) error {
+	d.Present = true
 	s := strings.Trim(string(data), `"`)
 	if s == "" || s == "null" {
-		d.Present = false
+		d.Null = true
 		return nil
 	}
 
@@ -27,6 +31,14 @@ func (d *NullableDate) UnmarshalJSON(data []byte) error {
 	}
 
 	d.Time = t
-	d.Present = true
 	return nil
-}
\ No newline at end of file
+}
+
+// Ptr returns the date as a pointer, nil when the member was null
+func (d NullableDate) Ptr() *time.Time {
+	if d.Null {
+		return nil
+	}
+	t := d.Time
+	return &t
+}
@@ -0,0 +1,22 @@
+package utils
+
+import "encoding/json"
+
+// PatchString is a string member of a JSON merge patch (RFC 7396).
+// Present tells whether the member was sent at all, Null whether it was sent as null.
+type PatchString struct {
+	Value   string
+	Present bool
+	Null    bool
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It is only called for members that are present in the patch.
+func (p *PatchString) UnmarshalJSON(data []byte) error {
+	p.Present = true
+	if string(data) == "null" {
+		p.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &p.Value)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PatchUint is an unsigned integer member of a JSON merge patch (RFC 7396).
+// Present tells whether the member was sent at all, Null whether it was sent as null.
+type PatchUint struct {
+	Value   uint
+	Present bool
+	Null    bool
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It is only called for members that are present in the patch.
+func (p *PatchUint) UnmarshalJSON(data []byte) error {
+	p.Present = true
+	if string(data) == "null" {
+		p.Null = true
+		return nil
+	}
+	if err := json.Unmarshal(data, &p.Value); err != nil {
+		return fmt.Errorf("invalid number: %s", string(data))
+	}
+	return nil
+}
+
+// Ptr returns the value as a pointer, nil when the member was null
+func (p PatchUint) Ptr() *uint {
+	if p.Null {
+		return nil
+	}
+	v := p.Value
+	return &v
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PatchUintList is a list of IDs in a JSON merge patch (RFC 7396).
+// Present tells whether the member was sent at all; null clears the list.
+type PatchUintList struct {
+	Values  []uint
+	Present bool
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It is only called for members that are present in the patch.
+func (p *PatchUintList) UnmarshalJSON(data []byte) error {
+	p.Present = true
+	p.Values = []uint{}
+	if string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, &p.Values); err != nil {
+		return fmt.Errorf("invalid ID list: %s", string(data))
+	}
+	return nil
+}
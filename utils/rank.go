@@ -0,0 +1,71 @@
+package utils
+
+import "strings"
+
+// rankDigits are the digits of board ranks, in sort order
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// RankBetween returns a rank that sorts strictly between before and after (fractional
+// indexing). An empty before means the start of the list, an empty after its end.
+// before must sort before after.
+func RankBetween(before, after string) string {
+	var rank strings.Builder
+	bounded := after != ""
+	for i := 0; ; i++ {
+		lo := 0
+		if i < len(before) {
+			lo = strings.IndexByte(rankDigits, before[i])
+		}
+		hi := len(rankDigits)
+		if bounded && i < len(after) {
+			hi = strings.IndexByte(rankDigits, after[i])
+		}
+
+		if hi-lo > 1 {
+			rank.WriteByte(rankDigits[(lo+hi)/2])
+			return rank.String()
+		}
+		rank.WriteByte(rankDigits[lo])
+		// Once below after at this position, later digits are only bounded by before
+		if hi-lo == 1 {
+			bounded = false
+		}
+	}
+}
+
+// RanksBetween returns n increasing ranks that sort strictly between before and after,
+// with the same bounds as RankBetween. They are spread by bisection, so their length only
+// grows with the logarithm of n.
+func RanksBetween(before, after string, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	middle := RankBetween(before, after)
+	ranks := RanksBetween(before, middle, n/2)
+	ranks = append(ranks, middle)
+	return append(ranks, RanksBetween(middle, after, n-n/2-1)...)
+}
+
+// EvenRanks returns n ranks of equal length spread evenly over the rank space,
+// for rebalancing a list whose ranks grew too long
+func EvenRanks(n int) []string {
+	base := len(rankDigits)
+	width, space := 1, base
+	for space < (n+1)*base {
+		width++
+		space *= base
+	}
+
+	step := space / (n + 1)
+	ranks := make([]string, n)
+	for k := range ranks {
+		v := (k + 1) * step
+		digits := make([]byte, width)
+		for i := width - 1; i >= 0; i-- {
+			digits[i] = rankDigits[v%base]
+			v /= base
+		}
+		ranks[k] = string(digits)
+	}
+	return ranks
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{"empty list", "", "", "i"},
+		{"start of the list", "", "a", "5"},
+		{"end of the list", "z", "", "zi"},
+		{"room between", "a", "c", "b"},
+		{"adjacent ranks", "a", "b", "ai"},
+		{"adjacent after a common prefix", "a0", "a1", "a0i"},
+		{"before is a prefix of after", "a", "ab", "a5"},
+		{"last digit before the end", "zz", "", "zzi"},
+		{"first rank above zero", "", "1", "0i"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RankBetween(tt.before, tt.after)
+			if got != tt.want {
+				t.Errorf("RankBetween(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+			}
+			assertRankBetween(t, tt.before, tt.after, got)
+		})
+	}
+}
+
+// assertRankBetween checks that rank sorts strictly between before and after, an empty
+// bound being the start or the end of the list
+func assertRankBetween(t *testing.T, before, after, rank string) {
+	t.Helper()
+	if rank <= before || (after != "" && rank >= after) {
+		t.Fatalf("RankBetween(%q, %q) = %q, which is not between them", before, after, rank)
+	}
+	if strings.Trim(rank, rankDigits) != "" {
+		t.Fatalf("RankBetween(%q, %q) = %q, which has digits outside the rank alphabet", before, after, rank)
+	}
+}
+
+func TestRankBetweenRepeatedInsertion(t *testing.T) {
+	const inserts = 200
+
+	tests := []struct {
+		name          string
+		before, after string
+		// next returns the bounds of the next insertion given the last inserted rank
+		next func(before, after, rank string) (string, string)
+	}{
+		{"at the start", "", "", func(before, after, rank string) (string, string) { return "", rank }},
+		{"at the end", "", "", func(before, after, rank string) (string, string) { return rank, "" }},
+		{"right after the same rank", "m", "n", func(before, after, rank string) (string, string) { return before, rank }},
+		{"right before the same rank", "m", "n", func(before, after, rank string) (string, string) { return rank, after }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := tt.before, tt.after
+			seen := make(map[string]bool)
+			for i := 0; i < inserts; i++ {
+				rank := RankBetween(before, after)
+				assertRankBetween(t, before, after, rank)
+				if seen[rank] {
+					t.Fatalf("rank %q was returned twice", rank)
+				}
+				seen[rank] = true
+				before, after = tt.next(before, after, rank)
+			}
+		})
+	}
+}
+
+func TestEvenRanks(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 35, 36, 1000, 50000} {
+		ranks := EvenRanks(n)
+		if len(ranks) != n {
+			t.Fatalf("EvenRanks(%d) returned %d ranks", n, len(ranks))
+		}
+		for i, rank := range ranks {
+			if len(rank) != len(ranks[0]) {
+				t.Fatalf("EvenRanks(%d): rank %q has length %d, want %d", n, rank, len(rank), len(ranks[0]))
+			}
+			if strings.TrimRight(rank, "0") == "" {
+				t.Fatalf("EvenRanks(%d): rank %q leaves no room at the start of the list", n, rank)
+			}
+			if i > 0 && rank <= ranks[i-1] {
+				t.Fatalf("EvenRanks(%d): rank %q does not sort after %q", n, rank, ranks[i-1])
+			}
+		}
+
+		// Every gap, and both ends, still accept an insertion
+		for i := 0; i <= n; i++ {
+			before, after := "", ""
+			if i > 0 {
+				before = ranks[i-1]
+			}
+			if i < n {
+				after = ranks[i]
+			}
+			assertRankBetween(t, before, after, RankBetween(before, after))
+		}
+	}
+}
+
+func TestRanksBetween(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		n             int
+	}{
+		{"none", "", "a", 0},
+		{"one", "", "a", 1},
+		{"whole space", "", "", 100},
+		{"before the first rank", "", "i", 1000},
+		{"between adjacent ranks", "a", "b", 1000},
+		{"after the last rank", "z", "", 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranks := RanksBetween(tt.before, tt.after, tt.n)
+			if len(ranks) != tt.n {
+				t.Fatalf("RanksBetween(%q, %q, %d) returned %d ranks", tt.before, tt.after, tt.n, len(ranks))
+			}
+			previous := tt.before
+			for _, rank := range ranks {
+				assertRankBetween(t, previous, tt.after, rank)
+				previous = rank
+				if len(rank) > len(tt.before)+len(tt.after)+4 {
+					t.Errorf("rank %q is longer than bisection needs", rank)
+				}
+			}
+		})
+	}
+}
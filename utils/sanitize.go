@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// TaskRefFunc resolves a task reference (#123) to the title of its link. Returning false
+// leaves the reference as plain text, e.g. for tasks the reader cannot see.
+type TaskRefFunc func(id uint) (title string, ok bool)
+
+// allowedElements lists the elements SanitizeHTML keeps, with their allowed attributes
+var allowedElements = map[string][]string{
+	"p": nil, "br": nil, "hr": nil, "div": nil, "span": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"strong": nil, "b": nil, "em": nil, "i": nil, "u": nil, "s": nil, "del": nil, "strike": nil,
+	"sub": nil, "sup": nil, "mark": nil,
+	"code": {"class"}, "pre": nil, "blockquote": nil,
+	"ul": nil, "ol": {"start"}, "li": nil,
+	"a":     {"href", "title"},
+	"img":   {"src", "alt", "title", "width", "height"},
+	"table": nil, "thead": nil, "tbody": nil, "tr": nil,
+	"th": {"align", "colspan", "rowspan"}, "td": {"align", "colspan", "rowspan"},
+}
+
+// droppedElements are removed with everything they contain
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "noscript": true,
+	"template": true, "textarea": true, "select": true, "title": true, "head": true, "svg": true, "math": true,
+}
+
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true}
+
+var (
+	taskRefPattern     = regexp.MustCompile(`(^|[^\w&#/])#([0-9]{1,9})\b`)
+	codeClassPattern   = regexp.MustCompile(`^language-[A-Za-z0-9_+-]+$`)
+	dataImagePattern   = regexp.MustCompile(`^data:image/(png|jpeg|gif|webp);base64,[A-Za-z0-9+/=\s]+$`)
+	numberPattern      = regexp.MustCompile(`^[0-9]{1,4}$`)
+	alignPattern       = regexp.MustCompile(`^(left|right|center)$`)
+	allowedLinkSchemes = map[string]bool{"": true, "http": true, "https": true, "mailto": true}
+)
+
+// TaskRefIDs returns the IDs of the task references (#123) in a text
+func TaskRefIDs(s string) []uint {
+	var ids []uint
+	for _, m := range taskRefPattern.FindAllStringSubmatch(s, -1) {
+		if id, err := strconv.ParseUint(m[2], 10, 64); err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// safeURL reports whether a link or image URL may be served: relative, http(s) or mailto.
+// Images may also be inline data of the common raster formats.
+func safeURL(value string, image bool) bool {
+	value = strings.TrimSpace(value)
+	if image && dataImagePattern.MatchString(value) {
+		return true
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	if image && u.Scheme == "mailto" {
+		return false
+	}
+	return allowedLinkSchemes[strings.ToLower(u.Scheme)]
+}
+
+// allowedAttribute reports whether an attribute value of an allowed element is safe
+func allowedAttribute(tag string, attr xhtml.Attribute) bool {
+	if attr.Namespace != "" {
+		return false
+	}
+	allowed := false
+	for _, name := range allowedElements[tag] {
+		if name == attr.Key {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return false
+	}
+	switch attr.Key {
+	case "href":
+		return safeURL(attr.Val, false)
+	case "src":
+		return safeURL(attr.Val, true)
+	case "class":
+		return codeClassPattern.MatchString(attr.Val)
+	case "start", "width", "height", "colspan", "rowspan":
+		return numberPattern.MatchString(attr.Val)
+	case "align":
+		return alignPattern.MatchString(attr.Val)
+	}
+	return true
+}
+
+// SanitizeHTML keeps the elements and attributes of a strict allowlist. Other elements are
+// unwrapped (their text is kept) except for scripts, styles and embedded content, which
+// are removed. Links may only point to relative, http(s) and mailto URLs and are marked
+// nofollow. Unclosed elements are closed and stray end tags dropped, so the result cannot
+// break the page it is inserted in. taskRef, if not nil, links task references outside
+// links and code.
+func SanitizeHTML(s string, taskRef TaskRefFunc) string {
+	var b strings.Builder
+	var open []string
+	openCount := make(map[string]int)
+	dropDepth := 0
+	literal := 0 // depth inside a, code and pre, where task references are not linked
+
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := tokenizer.Next()
+		if tt == xhtml.ErrorToken {
+			// io.EOF, or input too malformed to go on
+			break
+		}
+		token := tokenizer.Token()
+		tag := token.Data
+
+		switch tt {
+		case xhtml.TextToken:
+			if dropDepth > 0 {
+				continue
+			}
+			if taskRef != nil && literal == 0 {
+				b.WriteString(linkTaskRefs(token.Data, taskRef))
+			} else {
+				b.WriteString(html.EscapeString(token.Data))
+			}
+
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if droppedElements[tag] {
+				if tt == xhtml.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+			if dropDepth > 0 {
+				continue
+			}
+			if _, ok := allowedElements[tag]; !ok {
+				continue
+			}
+			b.WriteString("<" + tag)
+			for _, attr := range token.Attr {
+				if allowedAttribute(tag, attr) {
+					b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+				}
+			}
+			if tag == "a" {
+				b.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			b.WriteString(">")
+			if !voidElements[tag] && tt == xhtml.StartTagToken {
+				open = append(open, tag)
+				openCount[tag]++
+				if tag == "a" || tag == "code" || tag == "pre" {
+					literal++
+				}
+			}
+
+		case xhtml.EndTagToken:
+			if droppedElements[tag] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+			if dropDepth > 0 || voidElements[tag] || openCount[tag] == 0 {
+				continue
+			}
+			// Close up to the matching open element, ignoring end tags without one
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tag {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+					openCount[open[j]]--
+					if open[j] == "a" || open[j] == "code" || open[j] == "pre" {
+						literal--
+					}
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+// linkTaskRefs escapes text and links the task references taskRef resolves
+func linkTaskRefs(text string, taskRef TaskRefFunc) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range taskRefPattern.FindAllStringSubmatchIndex(text, -1) {
+		// m[4]:m[5] is the ID, preceded by "#"
+		id, err := strconv.ParseUint(text[m[4]:m[5]], 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		title, ok := taskRef(uint(id))
+		if !ok {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last : m[4]-1]))
+		fmt.Fprintf(&b, `<a href="/tasks?task=%d" class="task-ref" data-task-id="%d" title="%s">#%d</a>`, id, id, html.EscapeString(title), id)
+		last = m[5]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	xhtml "golang.org/x/net/html"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"allowed markup", `<p><strong>bold</strong> <em>em</em></p>`, `<p><strong>bold</strong> <em>em</em></p>`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript href with case and spaces", `<a href=" JaVaScRiPt:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"entity encoded javascript href", `<a href="&#106;avascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"hex entity encoded javascript href", `<a href="java&#x73;cript&#58;alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"tab inside the scheme", "<a href=\"java\tscript:alert(1)\">x</a>", `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"vbscript href", `<a href="vbscript:msgbox(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"data href", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"safe links", `<a href="https://example.com/a?b=1&amp;c=2" title="t">x</a> <a href="mailto:a@b.c">m</a>`,
+			`<a href="https://example.com/a?b=1&amp;c=2" title="t" rel="nofollow noopener noreferrer">x</a> <a href="mailto:a@b.c" rel="nofollow noopener noreferrer">m</a>`},
+		{"rel cannot be set", `<a href="/x" rel="opener" target="_blank">x</a>`, `<a href="/x" rel="nofollow noopener noreferrer">x</a>`},
+		{"event handlers", `<p onclick="alert(1)">x</p><img src="/a.png" onerror="alert(1)">`, `<p>x</p><img src="/a.png">`},
+		{"style attribute", `<span style="background:url(javascript:alert(1))">x</span>`, `<span>x</span>`},
+		{"script", `a<script>alert(1)</script>b`, `ab`},
+		{"script with markup inside", `<script><p>x</p></script>y`, `y`},
+		{"svg", `<svg onload="alert(1)"><script>alert(1)</script><a href="/x">x</a></svg>y`, `y`},
+		{"math", `<math><mi xlink:href="javascript:alert(1)">x</mi></math>y`, `y`},
+		{"iframe and object", `<iframe src="https://evil"></iframe><object data="x"></object>y`, `y`},
+		{"style element", `<style>p{}</style>y`, `y`},
+		{"unknown element is unwrapped", `<marquee>text</marquee>`, `text`},
+		{"unclosed tags are closed", `<p><strong>bold`, `<p><strong>bold</strong></p>`},
+		{"stray end tags are dropped", `</div>text</strong>`, `text`},
+		{"misnested tags", `<b><i>x</b>y</i>`, `<b><i>x</i></b>y`},
+		{"unclosed script drops the rest", `<script>alert(1)`, ``},
+		{"text is escaped", `a < b & "c"`, `a &lt; b &amp; &#34;c&#34;`},
+		{"attribute values are escaped", `<img src="/a.png" alt="&quot;><script>">`, `<img src="/a.png" alt="&#34;&gt;&lt;script&gt;">`},
+		{"code class", `<code class="language-go">x</code><code class="x onclick">y</code>`, `<code class="language-go">x</code><code>y</code>`},
+		{"data image", `<img src="data:image/png;base64,iVBORw0KGgo=">`, `<img src="data:image/png;base64,iVBORw0KGgo=">`},
+		{"data svg image", `<img src="data:image/svg+xml;base64,PHN2Zz4=">`, `<img>`},
+		{"data html image", `<img src="data:text/html;base64,PHNjcmlwdD4=">`, `<img>`},
+		{"javascript image", `<img src="javascript:alert(1)">`, `<img>`},
+		{"mailto image", `<img src="mailto:a@b.c">`, `<img>`},
+		{"numeric attributes", `<ol start="3"><li>x</li></ol><td colspan="2x">y</td>`, `<ol start="3"><li>x</li></ol><td>y</td>`},
+		{"comments are dropped", `a<!-- <script>alert(1)</script> -->b`, `ab`},
+	}
+
+	for _, tt := range tests {
+		if got := SanitizeHTML(tt.in, nil); got != tt.want {
+			t.Errorf("%s: SanitizeHTML(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeHTMLTaskRefs(t *testing.T) {
+	taskRef := func(id uint) (string, bool) {
+		if id == 404 {
+			return "", false
+		}
+		return `Task <"` + string(rune('0'+id%10)) + `">`, true
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"reference", `see #12.`, `see <a href="/tasks?task=12" class="task-ref" data-task-id="12" title="Task &lt;&#34;2&#34;&gt;">#12</a>.`},
+		{"reference the reader cannot see", `see #404`, `see #404`},
+		{"inside a link", `<a href="/x">#12</a>`, `<a href="/x" rel="nofollow noopener noreferrer">#12</a>`},
+		{"inside code", `<code>#12</code><pre>#13</pre>`, `<code>#12</code><pre>#13</pre>`},
+		{"after a closed link", `<a href="/x">a</a> #13`, `<a href="/x" rel="nofollow noopener noreferrer">a</a> <a href="/tasks?task=13" class="task-ref" data-task-id="13" title="Task &lt;&#34;3&#34;&gt;">#13</a>`},
+		{"not a reference", `a#12 /#12 #0 #1234567890`, `a#12 /#12 #0 #1234567890`},
+		{"text around is escaped", `<b>#1</b> & <`, `<b><a href="/tasks?task=1" class="task-ref" data-task-id="1" title="Task &lt;&#34;1&#34;&gt;">#1</a></b> &amp; &lt;`},
+	}
+
+	for _, tt := range tests {
+		if got := SanitizeHTML(tt.in, taskRef); got != tt.want {
+			t.Errorf("%s: SanitizeHTML(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTaskRefIDs(t *testing.T) {
+	got := TaskRefIDs("#1, see #23 and a#4 or #0 (#5)")
+	want := []uint{1, 23, 5}
+	if len(got) != len(want) {
+		t.Fatalf("TaskRefIDs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("TaskRefIDs = %v, want %v", got, want)
+		}
+	}
+}
+
+// assertSafeHTML checks that every element and attribute of HTML is allowed
+func assertSafeHTML(t *testing.T, name string, out string) {
+	t.Helper()
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := tokenizer.Next()
+		if tt == xhtml.ErrorToken {
+			return
+		}
+		if tt != xhtml.StartTagToken && tt != xhtml.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		if _, ok := allowedElements[token.Data]; !ok {
+			t.Errorf("%s: element %s in %q", name, token.Data, out)
+		}
+		for _, attr := range token.Attr {
+			value := strings.ToLower(strings.TrimSpace(attr.Val))
+			if strings.HasPrefix(attr.Key, "on") || strings.HasPrefix(value, "javascript:") || strings.HasPrefix(value, "data:text") {
+				t.Errorf("%s: attribute %s=%q in %q", name, attr.Key, attr.Val, out)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdownIsSanitized(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"javascript link", `[x](javascript:alert(1))`},
+		{"entity encoded javascript link", `[x](&#106;avascript:alert(1))`},
+		{"javascript autolink", `<javascript:alert(1)>`},
+		{"javascript image", `![x](javascript:alert(1))`},
+		{"data link", `[x](data:text/html;base64,PHNjcmlwdD4=)`},
+		{"raw script", "<script>alert(1)</script>"},
+		{"raw event handler", `<img src="/a.png" onerror="alert(1)">`},
+		{"quote in a link title", `[x](/a 't" onmouseover="alert(1)')`},
+		{"quote in a link", `[x](/a"onmouseover="alert(1))`},
+		{"quote in an image alt", `![x" onerror="alert(1)](/a.png)`},
+		{"raw svg", `<svg onload=alert(1)>`},
+		{"html block", "<div onclick=\"alert(1)\">\n<script>alert(1)</script>\n</div>"},
+	}
+
+	for _, tt := range tests {
+		assertSafeHTML(t, tt.name, RenderMarkdown(tt.in, nil))
+	}
+}